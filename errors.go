@@ -0,0 +1,75 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// These errors categorize the ways that received data can fail to parse. They are never returned on their own, but are
+// wrapped by a *FieldError or another error, so they should be tested for with errors.Is. All of them indicate a
+// problem with the request rather than a failure to authenticate it.
+var (
+	// ErrMalformed indicates that the data, or a value within it, could not be parsed.
+	ErrMalformed = errors.New("malformed data")
+	// ErrMissingField indicates that a field that Telegram always sends was absent.
+	ErrMissingField = errors.New("missing field")
+	// ErrBadHashEncoding indicates that the hash field was not a hex-encoded SHA-256 HMAC.
+	ErrBadHashEncoding = errors.New("hash is not 64 hexadecimal characters")
+	// ErrWrongType indicates that a JSON value did not have the type expected for its field.
+	ErrWrongType = errors.New("wrong type")
+)
+
+// A FieldError describes a problem with a single field of the received data. Reason wraps one of the error categories
+// above.
+type FieldError struct {
+	Field  string
+	Value  string
+	Reason error
+}
+
+func (e *FieldError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("field %s: %v", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("field %s with value %q: %v", e.Field, e.Value, e.Reason)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Reason
+}
+
+// newFieldError returns a *FieldError whose Reason wraps the category and, if it isn't nil, describes the cause.
+func newFieldError(field, value string, category, cause error) *FieldError {
+	reason := category
+	if cause != nil {
+		reason = fmt.Errorf("%w: %v", category, cause)
+	}
+	return &FieldError{Field: field, Value: value, Reason: reason}
+}
+
+// decodeHash decodes the value of a hash field into dst, which must have length sha256.Size.
+func decodeHash(v string, dst []byte) error {
+	if hex.DecodedLen(len(v)) != sha256.Size {
+		return newFieldError("hash", v, ErrBadHashEncoding, nil)
+	}
+	if _, err := hex.Decode(dst, []byte(v)); err != nil {
+		return newFieldError("hash", v, ErrBadHashEncoding, err)
+	}
+	return nil
+}
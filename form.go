@@ -16,9 +16,7 @@ package telegramwidget
 
 import (
 	"crypto/sha256"
-	"encoding/hex"
 	"errors"
-	"fmt"
 	"log"
 	"net/url"
 	"strconv"
@@ -26,7 +24,8 @@ import (
 )
 
 // ErrNotSingleValue indicates that the provided form has zero or more than one value for one of the parameters that
-// contains user data.
+// contains user data, or that JSON data has a duplicate key. Unlike the other parse errors, it is returned on its own
+// rather than inside a *FieldError, so existing comparisons with == keep working.
 var ErrNotSingleValue = errors.New("zero or multiple values for a key in form")
 
 // ConvertAndVerifyForm accepts form encoded data from the provided form and parses it into the returned User. The hash
@@ -61,7 +60,7 @@ func parseUserFromForm(f url.Values) (User, []pair, []byte, error) {
 			ps = append(ps, pair{"id", v})
 			var err error
 			if tu.ID, err = strconv.ParseInt(v, 10, 64); err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, v, ErrMalformed, err)
 			}
 		case "first_name":
 			ps = append(ps, pair{"first_name", v})
@@ -79,7 +78,7 @@ func parseUserFromForm(f url.Values) (User, []pair, []byte, error) {
 			ps = append(ps, pair{"photo_url", v})
 			var err error
 			if tu.PhotoURL, err = url.Parse(v); err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, v, ErrMalformed, err)
 			}
 			tu.HasPhotoURL = true
 		case "auth_date":
@@ -87,21 +86,26 @@ func parseUserFromForm(f url.Values) (User, []pair, []byte, error) {
 			// Fractional seconds are lost by this conversion.
 			seconds, err := strconv.ParseInt(v, 10, 64)
 			if err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, v, ErrMalformed, err)
 			}
 			tu.AuthDate = time.Unix(seconds, 0)
 		case "hash":
 			// This is only used to check validity, then is dropped.
-			if hex.DecodedLen(len(v)) != sha256.Size {
-				return tu, nil, expectedMAC, fmt.Errorf("hash must be 64 characters long, but wasn't")
-			}
-			if _, err := hex.Decode(expectedMAC, []byte(v)); err != nil {
-				return tu, nil, expectedMAC, fmt.Errorf("failure to decode incoming hash: %v", err)
+			if err := decodeHash(v, expectedMAC); err != nil {
+				return tu, nil, expectedMAC, err
 			}
 		default:
 			log.Printf("unexpected field in Telegram user: %s", k)
 		}
 	}
 
+	// Data without a hash can't be authenticated, and has always been reported as such.
+	if _, ok := f["hash"]; !ok {
+		return tu, nil, expectedMAC, ErrInvalidHash
+	}
+	if err := checkRequired(ps, "id", "auth_date"); err != nil {
+		return tu, nil, expectedMAC, err
+	}
+
 	return tu, ps, expectedMAC, nil
 }
@@ -15,6 +15,7 @@
 package telegramwidget
 
 import (
+	"errors"
 	"net/url"
 	"testing"
 	"time"
@@ -98,8 +99,8 @@ func TestConvertAndVerifyForm_WithMultipleHashes(t *testing.T) {
 		"photo_url": {"https://t.me/i/userpic/320/jsmith.jpg"},
 		"username":  {"jsmith"},
 	}, testBotToken)
-	if err == nil {
-		t.Error("expected validation error, but no error occurred")
+	if err != ErrNotSingleValue {
+		t.Errorf("expected ErrNotSingleValue, but was %v", err)
 	}
 }
 
@@ -140,3 +141,14 @@ func TestConvertAndVerifyForm_MarksMissingFields(t *testing.T) {
 		t.Error("username should be absent, but was present")
 	}
 }
+
+func TestConvertAndVerifyForm_WithShortHash(t *testing.T) {
+	_, err := ConvertAndVerifyForm(url.Values{
+		"auth_date": {"1512345678"},
+		"id":        {"12345678"},
+		"hash":      {"180f7d26"},
+	}, testBotToken)
+	if !errors.Is(err, ErrBadHashEncoding) {
+		t.Errorf("expected ErrBadHashEncoding, but was %v", err)
+	}
+}
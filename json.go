@@ -16,8 +16,8 @@ package telegramwidget
 
 import (
 	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -47,79 +47,106 @@ func parseUserFromJSON(r io.Reader) (User, []pair, []byte, error) {
 	// There are six supported properties.
 	ps := make([]pair, 0, 6)
 	expectedMAC := make([]byte, sha256.Size)
+	hasHash := false
+	seen := make(map[string]bool)
 
 	if t, err := d.Token(); err == io.EOF {
-		return tu, nil, expectedMAC, fmt.Errorf("expected start of object, got EOF")
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected start of object, got EOF", ErrMalformed)
 	} else if err != nil {
-		return tu, nil, expectedMAC, fmt.Errorf("expected start of object, got error: %v", err)
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected start of object, got error: %v", ErrMalformed, err)
 	} else if d, ok := t.(json.Delim); !ok || d != '{' {
-		return tu, nil, expectedMAC, fmt.Errorf("expected start of object, got token: %v", t)
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected start of object, got token: %v", ErrMalformed, t)
 	}
 
 	for d.More() {
-		k, err := d.Token()
+		t, err := d.Token()
 		if err != nil {
-			return tu, nil, expectedMAC, fmt.Errorf("expected key, got error: %v", err)
-		} else if d, ok := k.(json.Delim); ok {
+			return tu, nil, expectedMAC, fmt.Errorf("%w: expected key, got error: %v", ErrMalformed, err)
+		}
+		k, ok := t.(string)
+		if !ok {
 			// This case should be impossible in well-formed JSON. We're inside an object, so keys should always be
 			// strings.
-			return tu, nil, expectedMAC, fmt.Errorf("expected key, got delimeter: %v", d)
+			return tu, nil, expectedMAC, fmt.Errorf("%w: expected key, got token: %v", ErrMalformed, t)
+		}
+		if seen[k] {
+			return tu, nil, expectedMAC, ErrNotSingleValue
 		}
+		seen[k] = true
 
 		v, err := d.Token()
 		if err != nil {
-			return tu, nil, expectedMAC, fmt.Errorf("expected value, got error: %v", err)
+			return tu, nil, expectedMAC, fmt.Errorf("%w: expected value, got error: %v", ErrMalformed, err)
 		} else if _, ok := v.(json.Delim); ok {
-			return tu, nil, expectedMAC, fmt.Errorf("expected value, got delimeter: %v", v)
+			return tu, nil, expectedMAC, newFieldError(k, fmt.Sprint(v), ErrWrongType, nil)
 		}
 
 		switch k {
 		case "id":
-			id := v.(json.Number)
+			id, err := jsonNumber(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"id", id.String()})
 			if tu.ID, err = id.Int64(); err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, id.String(), ErrMalformed, err)
 			}
 		case "first_name":
-			firstName := v.(string)
+			firstName, err := jsonString(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"first_name", firstName})
 			tu.FirstName = firstName
 			tu.HasFirstName = true
 		case "last_name":
-			lastName := v.(string)
+			lastName, err := jsonString(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"last_name", lastName})
 			tu.LastName = lastName
 			tu.HasLastName = true
 		case "username":
-			username := v.(string)
+			username, err := jsonString(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"username", username})
 			tu.Username = username
 			tu.HasUsername = true
 		case "photo_url":
-			photoURL := v.(string)
+			photoURL, err := jsonString(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"photo_url", photoURL})
 			if tu.PhotoURL, err = url.Parse(photoURL); err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, photoURL, ErrMalformed, err)
 			}
 			tu.HasPhotoURL = true
 		case "auth_date":
-			authDate := v.(json.Number)
+			authDate, err := jsonNumber(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"auth_date", authDate.String()})
 			// Fractional seconds are lost by this conversion.
 			seconds, err := authDate.Int64()
 			if err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, authDate.String(), ErrMalformed, err)
 			}
 			tu.AuthDate = time.Unix(seconds, 0)
 		case "hash":
 			// This is only used to check validity, then is dropped.
-			hash := v.(string)
-			if hex.DecodedLen(len(hash)) != sha256.Size {
-				return tu, nil, expectedMAC, fmt.Errorf("hash must be 64 characters long, but wasn't")
+			hash, err := jsonString(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
 			}
-			if _, err := hex.Decode(expectedMAC, []byte(hash)); err != nil {
-				return tu, nil, expectedMAC, fmt.Errorf("failure to decode incoming hash: %v", err)
+			if err := decodeHash(hash, expectedMAC); err != nil {
+				return tu, nil, expectedMAC, err
 			}
+			hasHash = true
 		default:
 			log.Printf("unexpected field in Telegram user: %s", k)
 		}
@@ -129,20 +156,46 @@ func parseUserFromJSON(r io.Reader) (User, []pair, []byte, error) {
 	// The only way for d.More to return false is at the end of an object, and the end of an object should only be
 	// indicated by a '}' delimiter.
 	if t, err := d.Token(); err == io.EOF {
-		return tu, nil, expectedMAC, fmt.Errorf("expected end of object, got EOF")
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected end of object, got EOF", ErrMalformed)
 	} else if err != nil {
-		return tu, nil, expectedMAC, fmt.Errorf("expected end of object, got error: %v", err)
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected end of object, got error: %v", ErrMalformed, err)
 	} else if d, ok := t.(json.Delim); !ok || d != '}' {
-		return tu, nil, expectedMAC, fmt.Errorf("expected end of object, got token: %v", t)
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected end of object, got token: %v", ErrMalformed, t)
 	}
 
 	// A JSON document should only represent a single value, right? If so, the only possibility after closing the top
 	// level object is EOF.
 	if _, err := d.Token(); err == nil {
-		return tu, nil, expectedMAC, fmt.Errorf("expected EOF, but got a token")
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected EOF, but got a token", ErrMalformed)
 	} else if err != io.EOF {
-		return tu, nil, expectedMAC, fmt.Errorf("expected EOF, but got a different error: %v", err)
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected EOF, but got a different error: %v", ErrMalformed, err)
+	}
+
+	// Data without a hash can't be authenticated, and has always been reported as such.
+	if !hasHash {
+		return tu, nil, expectedMAC, ErrInvalidHash
+	}
+	if err := checkRequired(ps, "id", "auth_date"); err != nil {
+		return tu, nil, expectedMAC, err
 	}
 
 	return tu, ps, expectedMAC, nil
 }
+
+// jsonString returns v if it is a string, or a *FieldError if it isn't.
+func jsonString(k string, v json.Token) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", newFieldError(k, fmt.Sprint(v), ErrWrongType, errors.New("expected string"))
+	}
+	return s, nil
+}
+
+// jsonNumber returns v if it is a number, or a *FieldError if it isn't. The decoder must be configured with UseNumber.
+func jsonNumber(k string, v json.Token) (json.Number, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return "", newFieldError(k, fmt.Sprint(v), ErrWrongType, errors.New("expected number"))
+	}
+	return n, nil
+}
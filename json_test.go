@@ -15,6 +15,7 @@
 package telegramwidget
 
 import (
+	"errors"
 	"net/url"
 	"strings"
 	"testing"
@@ -162,3 +163,15 @@ func TestConvertAndVerifyJSON_MarksMissingFields(t *testing.T) {
 		t.Error("username should be absent, but was present")
 	}
 }
+
+func TestConvertAndVerifyJSON_WithStringID(t *testing.T) {
+	_, err := ConvertAndVerifyJSON(strings.NewReader(`{
+		"auth_date": 1512345678,
+		"id": "12345678",
+		"hash": "180f7d26839de06e6ecb26148f181553d24e1c62153400da55ae31483ee62ad3"
+	}`), testBotToken)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "id" || !errors.Is(err, ErrWrongType) {
+		t.Errorf("expected FieldError for id with ErrWrongType, but was %v", err)
+	}
+}
@@ -30,3 +30,20 @@ func estimateSize(ps []pair) int {
 	}
 	return s
 }
+
+// checkRequired returns a *FieldError if any of the keys is missing from ps.
+func checkRequired(ps []pair, keys ...string) error {
+	for _, k := range keys {
+		found := false
+		for _, p := range ps {
+			if p.key == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return newFieldError(k, "", ErrMissingField, nil)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// ConvertAndVerifyAuthResult accepts the value of the tgAuthResult fragment that oauth.telegram.org appends when it
+// redirects back after login, and parses it into the returned User. The value is base64-encoded JSON in the same form
+// accepted by ConvertAndVerifyJSON, and is verified the same way. Both the standard and URL-safe base64 alphabets are
+// accepted, with or without padding.
+func ConvertAndVerifyAuthResult(encoded string, tokenHash []byte) (User, error) {
+	b, err := decodeAuthResult(encoded)
+	if err != nil {
+		return User{}, err
+	}
+	return ConvertAndVerifyJSON(bytes.NewReader(b), tokenHash)
+}
+
+// ConvertAndVerifyAuthResult is like the package-level ConvertAndVerifyAuthResult, but verifies the data with v.
+func (v *Verifier) ConvertAndVerifyAuthResult(encoded string) (User, error) {
+	b, err := decodeAuthResult(encoded)
+	if err != nil {
+		return User{}, err
+	}
+	return v.ConvertAndVerifyJSON(bytes.NewReader(b))
+}
+
+func decodeAuthResult(encoded string) ([]byte, error) {
+	s := strings.TrimRight(encoded, "=")
+	s = strings.NewReplacer("-", "+", "_", "/").Replace(s)
+	b, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failure to decode auth result: %v", ErrMalformed, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+const testAuthResult = "eyJpZCI6MTIzNDU2NzgsImZpcnN0X25hbWUiOiJKb2huIPCflbYiLCJsYXN0X25hbWUiOiJTbWl0aCIsInVzZXJuYW1lIjoianNtaXRoIiwicGhvdG9fdXJsIjoiaHR0cHM6XC9cL3QubWVcL2lcL3VzZXJwaWNcLzMyMFwvanNtaXRoLmpwZyIsImF1dGhfZGF0ZSI6MTUxMjM0NTY3OCwiaGFzaCI6IjI1NDA5NzU5YzEwYmViMjliZDNmM2ZlMWQxNmVlMDYwNWFjODJlYjI5MDdkODg2ZTE5NmQ0ODEzNzFiOTE1MDEifQ"
+
+func TestConvertAndVerifyAuthResult_WithValidCredentials(t *testing.T) {
+	for _, encoded := range []string{testAuthResult, testAuthResult + "=="} {
+		u, err := ConvertAndVerifyAuthResult(encoded, testBotTokenHash)
+		if err != nil {
+			t.Fatalf("failed to convert and verify: %v", err)
+		}
+		if u.ID != 12345678 {
+			t.Errorf("ID should be 12345678, but was %d", u.ID)
+		}
+		if u.FirstName != "John 🕶" {
+			t.Errorf("first name should be John 🕶, but was %v", u.FirstName)
+		}
+	}
+}
+
+func TestConvertAndVerifyAuthResult_WithIncorrectHash(t *testing.T) {
+	_, err := ConvertAndVerifyAuthResult("eyJpZCI6MTIzNDU2NzgsImF1dGhfZGF0ZSI6MTUxMjM0NTY3OCwiaGFzaCI6IjAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAwMDAifQ", testBotTokenHash)
+	if err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyAuthResult_WithMalformedBase64(t *testing.T) {
+	_, err := ConvertAndVerifyAuthResult("not base64!", testBotTokenHash)
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed, but was %v", err)
+	}
+}
+
+func TestDecodeAuthResult_WithEitherAlphabet(t *testing.T) {
+	for _, encoded := range []string{"+/8", "-_8", "+/8=", "-_8="} {
+		b, err := decodeAuthResult(encoded)
+		if err != nil {
+			t.Errorf("failed to decode %v: %v", encoded, err)
+		} else if !bytes.Equal(b, []byte{0xfb, 0xff}) {
+			t.Errorf("%v should decode to fbff, but was %x", encoded, b)
+		}
+	}
+}
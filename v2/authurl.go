@@ -0,0 +1,131 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// OAuthEndpoint is the Telegram login page that AuthURL points to.
+const OAuthEndpoint = "https://oauth.telegram.org/auth"
+
+// An AuthURL describes a link to the Telegram login page. Following it lets the user log in without the login widget
+// being embedded in the page.
+//
+// The user data comes back in one of two ways, and the site must handle whichever it uses:
+//
+//   - With ReturnTo set, Telegram sends the browser to ReturnTo with the user data in a tgAuthResult fragment. A
+//     fragment is never sent to the server, so a script on the ReturnTo page must read it from location.hash and post
+//     it to the server, which verifies it with ConvertAndVerifyAuthResult. Unlike the query string handled by
+//     LoginHandler, the fragment stays out of server and proxy logs and Referer headers.
+//   - Without ReturnTo, the login page is meant to be opened as a popup, as the login widget does. Telegram posts the
+//     user data as a message to the window that opened it, where a data-onauth callback such as the relay script of
+//     JSONLoginHandler receives it as JSON.
+//
+// Telegram never sends the user data to ReturnTo in the query string, so LoginHandler can't be used as ReturnTo.
+type AuthURL struct {
+	// BotID is the numeric ID of the bot, which is the part of its token before the colon.
+	BotID int64
+
+	// Origin is the scheme and host of the site that users are logging in to, such as "https://example.com". It must
+	// be the domain set for the bot with BotFather's /setdomain command.
+	Origin string
+
+	// ReturnTo, if not empty, is where Telegram sends the browser after login. It must be an absolute URL with the
+	// same origin as Origin.
+	ReturnTo string
+
+	// RequestAccess, if not empty, asks the user for more permissions. The only value Telegram supports is "write",
+	// which lets the bot send messages to the user.
+	RequestAccess string
+
+	// Language, if not empty, is the IETF language tag for the login page, such as "en".
+	Language string
+
+	// Embed requests the compact page used inside the login widget's popup.
+	Embed bool
+}
+
+// URL validates a and returns the URL of the login page.
+func (a AuthURL) URL() (*url.URL, error) {
+	if a.BotID <= 0 {
+		return nil, errors.New("bot ID must be positive")
+	}
+
+	origin, err := parseOrigin(a.Origin)
+	if err != nil {
+		return nil, err
+	}
+
+	q := url.Values{
+		"bot_id": {strconv.FormatInt(a.BotID, 10)},
+		"origin": {origin.String()},
+	}
+	if a.ReturnTo != "" {
+		r, err := url.Parse(a.ReturnTo)
+		if err != nil {
+			return nil, fmt.Errorf("invalid return_to: %v", err)
+		}
+		if r.Scheme != origin.Scheme || r.Host != origin.Host {
+			return nil, fmt.Errorf("return_to %q is not on origin %q", a.ReturnTo, origin)
+		}
+		q.Set("return_to", r.String())
+	}
+	switch a.RequestAccess {
+	case "":
+	case "write":
+		q.Set("request_access", a.RequestAccess)
+	default:
+		return nil, fmt.Errorf("unsupported request_access %q", a.RequestAccess)
+	}
+	if a.Language != "" {
+		q.Set("lang", a.Language)
+	}
+	if a.Embed {
+		q.Set("embed", "1")
+	}
+
+	u, err := url.Parse(OAuthEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	u.RawQuery = q.Encode()
+	return u, nil
+}
+
+// String returns the URL of the login page, or the empty string if a is invalid.
+func (a AuthURL) String() string {
+	u, err := a.URL()
+	if err != nil {
+		return ""
+	}
+	return u.String()
+}
+
+// parseOrigin parses s, which must be an http or https URL with nothing after the host.
+func parseOrigin(s string) (*url.URL, error) {
+	o, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid origin: %v", err)
+	}
+	if (o.Scheme != "https" && o.Scheme != "http") || o.Host == "" || o.User != nil ||
+		(o.Path != "" && o.Path != "/") || o.RawQuery != "" || o.Fragment != "" {
+		return nil, fmt.Errorf("origin %q must be a scheme and host only", s)
+	}
+	return &url.URL{Scheme: o.Scheme, Host: o.Host}, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import "testing"
+
+func TestAuthURL_WithAllOptions(t *testing.T) {
+	u, err := AuthURL{
+		BotID:         testBotID,
+		Origin:        "https://example.com/",
+		ReturnTo:      "https://example.com/login/done?x=1",
+		RequestAccess: "write",
+		Language:      "en",
+		Embed:         true,
+	}.URL()
+	if err != nil {
+		t.Fatalf("failed to build URL: %v", err)
+	}
+	want := "https://oauth.telegram.org/auth?bot_id=123456789&embed=1&lang=en&origin=https%3A%2F%2Fexample.com&request_access=write&return_to=https%3A%2F%2Fexample.com%2Flogin%2Fdone%3Fx%3D1"
+	if s := u.String(); s != want {
+		t.Errorf("URL should be %v, but was %v", want, s)
+	}
+}
+
+func TestAuthURL_WithMinimalOptions(t *testing.T) {
+	want := "https://oauth.telegram.org/auth?bot_id=123456789&origin=https%3A%2F%2Fexample.com"
+	if s := (AuthURL{BotID: testBotID, Origin: "https://example.com"}).String(); s != want {
+		t.Errorf("URL should be %v, but was %v", want, s)
+	}
+}
+
+func TestAuthURL_WithInvalidOptions(t *testing.T) {
+	for _, a := range []AuthURL{
+		{Origin: "https://example.com"},
+		{BotID: testBotID, Origin: "example.com"},
+		{BotID: testBotID, Origin: "https://example.com/login"},
+		{BotID: testBotID, Origin: "ftp://example.com"},
+		{BotID: testBotID, Origin: "https://example.com", ReturnTo: "https://evil.example/"},
+		{BotID: testBotID, Origin: "https://example.com", ReturnTo: "http://example.com/"},
+		{BotID: testBotID, Origin: "https://example.com", ReturnTo: "/relative"},
+		{BotID: testBotID, Origin: "https://example.com", RequestAccess: "read"},
+	} {
+		if _, err := a.URL(); err == nil {
+			t.Errorf("%+v should be invalid, but wasn't", a)
+		}
+	}
+}
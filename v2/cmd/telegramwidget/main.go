@@ -0,0 +1,450 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command telegramwidget verifies, signs and explains Telegram login data. It is meant for debugging data that fails
+// verification without writing a program to do it.
+//
+// Usage:
+//
+//	telegramwidget verify [-token-file file] [-webapp] [-max-age duration] [input]
+//	telegramwidget sign [-token-file file] [-webapp] [-format format] [-now] [input]
+//	telegramwidget explain [-token-file file] [-webapp] [input]
+//
+// The input is a URL, a query string, a JSON object, a tgAuthResult value or Mini App initData. Its format is
+// detected, and -webapp forces a query string to be treated as initData. If the input is omitted or "-", it is read
+// from standard input.
+//
+// The bot token is read from the file named by -token-file, or else from the TELEGRAM_BOT_TOKEN environment variable.
+// It is never accepted as an argument, so that it doesn't end up in shell history or process listings.
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wesleym/telegramwidget/v2"
+	"github.com/wesleym/telegramwidget/v2/telegramwidgettest"
+)
+
+// tokenEnv is the environment variable that holds the bot token when -token-file isn't given.
+const tokenEnv = "TELEGRAM_BOT_TOKEN"
+
+const usage = `usage: telegramwidget <command> [flags] [input]
+
+Commands:
+  verify   check the hash of the input and print the user it describes
+  sign     print the input signed with the bot token
+  explain  print the data-check-string and the computed and expected hashes
+
+The input is a URL, a query string, a JSON object, a tgAuthResult value or Mini
+App initData. If it is omitted or "-", it is read from standard input.
+
+The bot token is read from the file named by -token-file, or else from the
+` + tokenEnv + ` environment variable.
+
+Run "telegramwidget <command> -h" for the flags of a command.
+`
+
+// now is the current time. Tests replace it.
+var now = time.Now
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command described by args and returns the exit status: 0 for success, 1 if the input is invalid and 2
+// for usage errors.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var cmd func(c *command) error
+	fs := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	c := &command{stdout: stdout}
+	fs.StringVar(&c.tokenFile, "token-file", "", "read the bot token from `file` instead of $"+tokenEnv)
+	fs.BoolVar(&c.webApp, "webapp", false, "treat a query string as Mini App initData")
+	switch args[0] {
+	case "verify":
+		cmd = verify
+		fs.DurationVar(&c.maxAge, "max-age", 0, "report data older than `duration` as expired")
+	case "sign":
+		cmd = sign
+		fs.StringVar(&c.format, "format", "", "output `format`: query, json, tgAuthResult or webapp (default: the input's)")
+		fs.BoolVar(&c.setAuthDate, "now", false, "set auth_date to the current time before signing")
+	case "explain":
+		cmd = explain
+	case "help", "-h", "-help", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "telegramwidget: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintf(stderr, "telegramwidget %s: too many arguments\n", args[0])
+		return 2
+	}
+
+	input := fs.Arg(0)
+	if input == "" || input == "-" {
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "telegramwidget %s: %v\n", args[0], err)
+			return 2
+		}
+		input = string(b)
+	}
+
+	token, err := readToken(c.tokenFile)
+	if err != nil {
+		fmt.Fprintf(stderr, "telegramwidget %s: %v\n", args[0], err)
+		return 2
+	}
+	c.token = token
+
+	if c.payload, err = parseInput(input, c.webApp); err != nil {
+		fmt.Fprintf(stderr, "telegramwidget %s: %v\n", args[0], err)
+		return 2
+	}
+
+	if err := cmd(c); err != nil {
+		fmt.Fprintf(stderr, "telegramwidget %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// A command holds the flags and input of a command.
+type command struct {
+	stdout io.Writer
+
+	tokenFile   string
+	webApp      bool
+	maxAge      time.Duration
+	format      string
+	setAuthDate bool
+
+	token   telegramwidget.BotToken
+	payload payload
+}
+
+// key returns the key that data in format f is signed with.
+func (c *command) key(f string) []byte {
+	if f == formatWebApp {
+		return c.token.WebAppSecretKey()
+	}
+	return c.token.Hash()
+}
+
+// readToken reads the bot token from file, or from the environment if file is empty.
+func readToken(file string) (telegramwidget.BotToken, error) {
+	source, s := tokenEnv, os.Getenv(tokenEnv)
+	if file != "" {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return telegramwidget.BotToken{}, err
+		}
+		source, s = file, string(b)
+	} else if s == "" {
+		return telegramwidget.BotToken{}, fmt.Errorf("no bot token: set %s or use -token-file", tokenEnv)
+	}
+	token, err := telegramwidget.ParseBotToken(strings.TrimSpace(s))
+	if err != nil {
+		return token, fmt.Errorf("%s: %v", source, err)
+	}
+	return token, nil
+}
+
+// These are the input and output formats.
+const (
+	formatQuery      = "query"
+	formatJSON       = "json"
+	formatAuthResult = "tgAuthResult"
+	formatWebApp     = "webapp"
+)
+
+// A payload is user data in one of the formats that Telegram sends.
+type payload struct {
+	// format is the format of the data.
+	format string
+
+	// raw is the data as it would be passed to the matching verification function.
+	raw string
+
+	// fields are the fields of the data, with JSON values converted to strings the same way package telegramwidget
+	// does.
+	fields url.Values
+}
+
+// parseInput detects the format of s and parses it.
+func parseInput(s string, webApp bool) (payload, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") {
+		return jsonPayload(formatJSON, s, []byte(s))
+	}
+
+	if strings.Contains(s, "://") || strings.HasPrefix(s, "/") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return payload{}, err
+		}
+		// oauth.telegram.org returns the data in the fragment, and Mini Apps are launched with it there.
+		frag, _ := url.ParseQuery(u.Fragment)
+		switch {
+		case frag.Has("tgAuthResult"):
+			s = "tgAuthResult=" + url.QueryEscape(frag.Get("tgAuthResult"))
+		case frag.Has("tgWebAppData"):
+			s = frag.Get("tgWebAppData")
+			webApp = true
+		default:
+			s = u.RawQuery
+		}
+	}
+	s = strings.TrimPrefix(s, "?")
+
+	if !strings.ContainsAny(strings.TrimRight(s, "="), "=&") {
+		// A bare tgAuthResult value.
+		s = "tgAuthResult=" + url.QueryEscape(s)
+	}
+	q, err := url.ParseQuery(s)
+	if err != nil {
+		return payload{}, err
+	}
+	if q.Has("tgAuthResult") {
+		encoded := q.Get("tgAuthResult")
+		b, err := base64.RawURLEncoding.DecodeString(
+			strings.NewReplacer("+", "-", "/", "_").Replace(strings.TrimRight(encoded, "=")))
+		if err != nil {
+			return payload{}, fmt.Errorf("invalid tgAuthResult: %v", err)
+		}
+		return jsonPayload(formatAuthResult, encoded, b)
+	}
+	if webApp || q.Has("user") || q.Has("query_id") || q.Has("receiver") || q.Has("chat") {
+		return payload{format: formatWebApp, raw: s, fields: q}, nil
+	}
+	return payload{format: formatQuery, raw: s, fields: q}, nil
+}
+
+// jsonPayload returns a payload in format f whose fields are the properties of the JSON object b.
+func jsonPayload(f, raw string, b []byte) (payload, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var m map[string]interface{}
+	if err := d.Decode(&m); err != nil {
+		return payload{}, fmt.Errorf("invalid JSON: %v", err)
+	}
+	fields := make(url.Values, len(m))
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			fields.Set(k, v)
+		case json.Number:
+			fields.Set(k, v.String())
+		case bool:
+			fields.Set(k, strconv.FormatBool(v))
+		case nil:
+			fields.Set(k, "null")
+		default:
+			return payload{}, fmt.Errorf("field %s is not a string, number or boolean", k)
+		}
+	}
+	return payload{format: f, raw: raw, fields: fields}, nil
+}
+
+// verify checks the hash of the input and prints what it describes.
+func verify(c *command) error {
+	p := c.payload
+	fmt.Fprintf(c.stdout, "format: %s\n", p.format)
+
+	// Only the Mini App format has its own type, so everything else is verified into u.
+	var u telegramwidget.User
+	var d telegramwidget.WebAppInitData
+	var err error
+	switch p.format {
+	case formatQuery:
+		u, err = telegramwidget.ConvertAndVerifyForm(p.fields, c.key(p.format))
+	case formatJSON:
+		u, err = telegramwidget.ConvertAndVerifyJSON(strings.NewReader(p.raw), c.key(p.format))
+	case formatAuthResult:
+		u, err = telegramwidget.ConvertAndVerifyAuthResult(p.raw, c.key(p.format))
+	case formatWebApp:
+		d, err = telegramwidget.ConvertAndVerifyWebAppInitData(p.raw, c.key(p.format))
+	}
+	if err != nil {
+		fmt.Fprintln(c.stdout, "valid: no")
+		if errors.Is(err, telegramwidget.ErrInvalidHash) {
+			return fmt.Errorf("%v (run explain for details)", err)
+		}
+		return err
+	}
+	fmt.Fprintln(c.stdout, "valid: yes")
+
+	authDate := u.AuthDate
+	if p.format == formatWebApp {
+		authDate = d.AuthDate
+	}
+	age := now().Sub(authDate).Truncate(time.Second)
+	fmt.Fprintf(c.stdout, "auth_date: %s (age %v)\n", authDate.UTC().Format(time.RFC3339), age)
+	if p.format == formatWebApp {
+		printWebAppInitData(c.stdout, d)
+	} else {
+		printUser(c.stdout, u)
+	}
+	switch {
+	case age < 0:
+		return fmt.Errorf("auth_date is %v in the future", -age)
+	case c.maxAge > 0 && age > c.maxAge:
+		return fmt.Errorf("auth_date is older than %v", c.maxAge)
+	}
+	return nil
+}
+
+func printUser(w io.Writer, u telegramwidget.User) {
+	fmt.Fprintf(w, "id: %d\n", u.ID)
+	if u.Has(telegramwidget.FieldFirstName) {
+		fmt.Fprintf(w, "first_name: %q\n", u.FirstName)
+	}
+	if u.Has(telegramwidget.FieldLastName) {
+		fmt.Fprintf(w, "last_name: %q\n", u.LastName)
+	}
+	if u.Has(telegramwidget.FieldUsername) {
+		fmt.Fprintf(w, "username: %q\n", u.Username)
+	}
+	if u.Has(telegramwidget.FieldPhotoURL) {
+		fmt.Fprintf(w, "photo_url: %q\n", u.PhotoURL)
+	}
+	keys := make([]string, 0, len(u.Extra))
+	for k := range u.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s: %q (not known to this library)\n", k, u.Extra[k])
+	}
+}
+
+func printWebAppInitData(w io.Writer, d telegramwidget.WebAppInitData) {
+	b, _ := json.MarshalIndent(d, "", "  ")
+	fmt.Fprintf(w, "init data: %s\n", b)
+}
+
+// sign prints the input signed with the bot token.
+func sign(c *command) error {
+	f := c.format
+	if f == "" {
+		f = c.payload.format
+	}
+	switch f {
+	case formatQuery, formatJSON, formatAuthResult, formatWebApp:
+	default:
+		return fmt.Errorf("unknown format %q", f)
+	}
+
+	fields := make(url.Values, len(c.payload.fields))
+	for k, vs := range c.payload.fields {
+		fields[k] = vs
+	}
+	if c.setAuthDate {
+		fields.Set("auth_date", strconv.FormatInt(now().Unix(), 10))
+	}
+	signed, err := telegramwidget.SignForm(fields, c.key(f))
+	if err != nil {
+		return err
+	}
+
+	switch f {
+	case formatJSON:
+		fmt.Fprintf(c.stdout, "%s\n", telegramwidgettest.EncodeJSON(signed))
+	case formatAuthResult:
+		fmt.Fprintln(c.stdout, base64.RawURLEncoding.EncodeToString(telegramwidgettest.EncodeJSON(signed)))
+	default:
+		fmt.Fprintln(c.stdout, signed.Encode())
+	}
+	return nil
+}
+
+// explain prints how the hash of the input is computed, and whether it matches.
+func explain(c *command) error {
+	p := c.payload
+	s, err := telegramwidget.DataCheckString(p.fields)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "format: %s\n", p.format)
+	if p.format == formatWebApp {
+		fmt.Fprintln(c.stdout, `key: HMAC-SHA-256(key "WebAppData", bot token)`)
+	} else {
+		fmt.Fprintln(c.stdout, "key: SHA-256(bot token)")
+	}
+	fmt.Fprintf(c.stdout, "data-check-string:\n%s\n", s)
+	fmt.Fprintf(c.stdout, "data-check-string (quoted): %q\n", s)
+
+	computed := computeHash(c.key(p.format), s)
+	expected := p.fields.Get("hash")
+	fmt.Fprintf(c.stdout, "expected hash: %s\n", expected)
+	fmt.Fprintf(c.stdout, "computed hash: %s\n", computed)
+
+	switch {
+	case expected == "":
+		return errors.New("the input has no hash")
+	case strings.EqualFold(expected, computed):
+		fmt.Fprintln(c.stdout, "result: match")
+		return nil
+	}
+	fmt.Fprintln(c.stdout, "result: mismatch")
+
+	// The most common mistake is using the key for the other product.
+	other := formatWebApp
+	if p.format == formatWebApp {
+		other = formatQuery
+	}
+	if strings.EqualFold(expected, computeHash(c.key(other), s)) {
+		if other == formatWebApp {
+			return errors.New("hashes differ, but the hash matches the Mini App key: verify it as initData with -webapp")
+		}
+		return errors.New("hashes differ, but the hash matches the login widget key: this isn't Mini App initData")
+	}
+	return errors.New("hashes differ: the token is wrong or the data was changed after signing")
+}
+
+func computeHash(key []byte, s string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/wesleym/telegramwidget/v2/telegramwidgettest"
+)
+
+const testForm = "auth_date=1512345678&first_name=John+%F0%9F%95%B6&hash=25409759c10beb29bd3f3fe1d16ee0605ac82eb2907d886e196d481371b91501&id=12345678&last_name=Smith&photo_url=https%3A%2F%2Ft.me%2Fi%2Fuserpic%2F320%2Fjsmith.jpg&username=jsmith"
+
+// runWithToken runs args with the test token in the environment and returns the exit status and output.
+func runWithToken(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	t.Setenv(tokenEnv, telegramwidgettest.DefaultToken)
+	now = func() time.Time { return time.Unix(1512345678, 0).Add(time.Hour) }
+	defer func() { now = time.Now }()
+
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestVerify_Query(t *testing.T) {
+	status, stdout, stderr := runWithToken(t, "", "verify", "https://example.com/login?"+testForm)
+	if status != 0 {
+		t.Fatalf("status should be 0, but was %d: %s", status, stderr)
+	}
+	for _, want := range []string{"format: query\n", "valid: yes\n", "(age 1h0m0s)", `username: "jsmith"`} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("output should contain %q, but was %q", want, stdout)
+		}
+	}
+}
+
+func TestVerify_Expired(t *testing.T) {
+	status, _, stderr := runWithToken(t, testForm, "verify", "-max-age", "1m")
+	if status != 1 {
+		t.Errorf("status should be 1, but was %d", status)
+	}
+	if !strings.Contains(stderr, "older than 1m0s") {
+		t.Errorf("error should mention age, but was %q", stderr)
+	}
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	status, stdout, _ := runWithToken(t, "", "verify", strings.Replace(testForm, "jsmith.jpg", "other.jpg", 1))
+	if status != 1 {
+		t.Errorf("status should be 1, but was %d", status)
+	}
+	if !strings.Contains(stdout, "valid: no\n") {
+		t.Errorf("output should report invalid, but was %q", stdout)
+	}
+}
+
+func TestSign_RoundTrip(t *testing.T) {
+	for _, format := range []string{"query", "json", "tgAuthResult", "webapp"} {
+		t.Run(format, func(t *testing.T) {
+			status, signed, stderr := runWithToken(t, "", "sign", "-format", format, "id=1&auth_date=1512345678&first_name=Ann")
+			if status != 0 {
+				t.Fatalf("sign status should be 0, but was %d: %s", status, stderr)
+			}
+			args := []string{"verify", strings.TrimSpace(signed)}
+			if format == "webapp" {
+				args = []string{"verify", "-webapp", strings.TrimSpace(signed)}
+			}
+			status, stdout, stderr := runWithToken(t, "", args...)
+			if status != 0 {
+				t.Errorf("verify status should be 0, but was %d: %s", status, stderr)
+			}
+			if !strings.Contains(stdout, "format: "+format+"\n") {
+				t.Errorf("format should be %s, but output was %q", format, stdout)
+			}
+		})
+	}
+}
+
+func TestExplain(t *testing.T) {
+	status, stdout, stderr := runWithToken(t, testForm, "explain")
+	if status != 0 {
+		t.Fatalf("status should be 0, but was %d: %s", status, stderr)
+	}
+	if !strings.Contains(stdout, "data-check-string:\nauth_date=1512345678\nfirst_name=John 🕶\nid=12345678\n") {
+		t.Errorf("output should contain the check string, but was %q", stdout)
+	}
+	if !strings.Contains(stdout, "result: match\n") {
+		t.Errorf("output should report a match, but was %q", stdout)
+	}
+}
+
+func TestExplain_WithWebAppKey(t *testing.T) {
+	_, signed, _ := runWithToken(t, "", "sign", "-format", "webapp", "auth_date=1512345678&id=1")
+	status, stdout, stderr := runWithToken(t, signed, "explain")
+	if status != 1 {
+		t.Errorf("status should be 1, but was %d", status)
+	}
+	if !strings.Contains(stdout, "result: mismatch\n") || !strings.Contains(stderr, "-webapp") {
+		t.Errorf("output should suggest -webapp, but was %q and %q", stdout, stderr)
+	}
+}
+
+func TestTokenFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte(telegramwidgettest.DefaultToken+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(tokenEnv, "")
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"verify", "-token-file", file, testForm}, nil, &stdout, &stderr); status != 0 {
+		t.Errorf("status should be 0, but was %d: %s", status, stderr.String())
+	}
+}
+
+func TestNoToken(t *testing.T) {
+	t.Setenv(tokenEnv, "")
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"verify", testForm}, nil, &stdout, &stderr); status != 2 {
+		t.Errorf("status should be 2, but was %d", status)
+	}
+}
+
+func TestMalformedToken(t *testing.T) {
+	t.Setenv(tokenEnv, "not a token")
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"verify", testForm}, nil, &stdout, &stderr); status != 2 {
+		t.Errorf("status should be 2, but was %d", status)
+	}
+	if strings.Contains(stderr.String(), "not a token") {
+		t.Errorf("error should not contain the token, but was %q", stderr.String())
+	}
+}
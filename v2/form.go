@@ -16,23 +16,22 @@ package telegramwidget
 
 import (
 	"crypto/sha256"
-	"encoding/hex"
 	"errors"
-	"fmt"
-	"log"
 	"net/url"
 	"strconv"
 	"time"
 )
 
 // ErrNotSingleValue indicates that the provided form has zero or more than one value for one of the parameters that
-// contains user data.
+// contains user data, or that JSON data has a duplicate key. It is returned inside a *FieldError that names the field,
+// so it should be tested for with errors.Is. Version 1 of this package returns it on its own, and code that compares
+// errors with == must be updated when moving to version 2.
 var ErrNotSingleValue = errors.New("zero or multiple values for a key in form")
 
 // ConvertAndVerifyForm accepts form encoded data from the provided form and parses it into the returned User. The hash
 // property of the input form is used to validate the user data before it is returned.
 func ConvertAndVerifyForm(f url.Values, tokenHash []byte) (User, error) {
-	u, ps, expectedMAC, err := parseUserFromForm(f)
+	u, ps, expectedMAC, err := parseUserFromForm(f, DefaultLimits)
 	if err != nil {
 		return u, err
 	}
@@ -44,60 +43,91 @@ func ConvertAndVerifyForm(f url.Values, tokenHash []byte) (User, error) {
 	return u, nil
 }
 
-func parseUserFromForm(f url.Values) (User, []pair, []byte, error) {
+func parseUserFromForm(f url.Values, l Limits) (User, []pair, []byte, error) {
 	var tu User
-	// There are six supported properties.
-	ps := make([]pair, 0, 6)
 	expectedMAC := make([]byte, sha256.Size)
 
+	if err := l.checkFieldCount(len(f)); err != nil {
+		return tu, nil, expectedMAC, err
+	}
+	var n int64
+	for k, vs := range f {
+		for _, v := range vs {
+			n += int64(len(k) + len(v))
+		}
+	}
+	if err := l.checkBytes(n); err != nil {
+		return tu, nil, expectedMAC, err
+	}
+
+	// There are six supported properties, but more may be present.
+	ps := make([]pair, 0, len(f))
+
 	for k, vs := range f {
 		if len(vs) != 1 {
-			return tu, nil, expectedMAC, ErrNotSingleValue
+			return tu, nil, expectedMAC, newFieldError(k, "", ErrNotSingleValue, nil)
 		}
 		v := vs[0]
+		if err := l.checkField(k, v); err != nil {
+			return tu, nil, expectedMAC, err
+		}
 
 		switch k {
 		case "id":
 			ps = append(ps, pair{"id", v})
 			var err error
 			if tu.ID, err = strconv.ParseInt(v, 10, 64); err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, v, ErrMalformed, err)
 			}
 		case "first_name":
 			ps = append(ps, pair{"first_name", v})
 			tu.FirstName = v
+			tu.Fields |= FieldFirstName
 		case "last_name":
 			ps = append(ps, pair{"last_name", v})
 			tu.LastName = v
+			tu.Fields |= FieldLastName
 		case "username":
 			ps = append(ps, pair{"username", v})
 			tu.Username = v
+			tu.Fields |= FieldUsername
 		case "photo_url":
 			ps = append(ps, pair{"photo_url", v})
 			var err error
 			if tu.PhotoURL, err = url.Parse(v); err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, v, ErrMalformed, err)
 			}
+			tu.Fields |= FieldPhotoURL
 		case "auth_date":
 			ps = append(ps, pair{"auth_date", v})
 			// Fractional seconds are lost by this conversion.
 			seconds, err := strconv.ParseInt(v, 10, 64)
 			if err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, v, ErrMalformed, err)
 			}
 			tu.AuthDate = time.Unix(seconds, 0)
 		case "hash":
 			// This is only used to check validity, then is dropped.
-			if hex.DecodedLen(len(v)) != sha256.Size {
-				return tu, nil, expectedMAC, fmt.Errorf("hash must be 64 characters long, but wasn't")
-			}
-			if _, err := hex.Decode(expectedMAC, []byte(v)); err != nil {
-				return tu, nil, expectedMAC, fmt.Errorf("failure to decode incoming hash: %v", err)
+			if err := decodeHash(v, expectedMAC); err != nil {
+				return tu, nil, expectedMAC, err
 			}
 		default:
-			log.Printf("unexpected field in Telegram user: %s", k)
+			// Telegram signs every field it sends, so fields added after this library was written must still be part
+			// of the check string.
+			ps = append(ps, pair{k, v})
+			if tu.Extra == nil {
+				tu.Extra = make(map[string]string)
+			}
+			tu.Extra[k] = v
 		}
 	}
 
+	if _, ok := f["hash"]; !ok {
+		return tu, nil, expectedMAC, newFieldError("hash", "", ErrMissingHash, nil)
+	}
+	if err := checkRequired(ps, "id", "auth_date"); err != nil {
+		return tu, nil, expectedMAC, err
+	}
+
 	return tu, ps, expectedMAC, nil
 }
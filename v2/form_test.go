@@ -15,6 +15,7 @@
 package telegramwidget
 
 import (
+	"errors"
 	"net/url"
 	"testing"
 	"time"
@@ -57,6 +58,9 @@ func TestConvertAndVerifyForm_WithValidCredentials(t *testing.T) {
 	if u.Username != "jsmith" {
 		t.Errorf("username should be jsmith, but was %s", u.Username)
 	}
+	if !u.Has(FieldFirstName | FieldLastName | FieldPhotoURL | FieldUsername) {
+		t.Errorf("all fields should be present, but were %b", u.Fields)
+	}
 }
 
 func TestConvertAndVerifyForm_WithoutHash(t *testing.T) {
@@ -68,8 +72,8 @@ func TestConvertAndVerifyForm_WithoutHash(t *testing.T) {
 		"photo_url":  {"https://t.me/i/userpic/320/jsmith.jpg"},
 		"username":   {"jsmith"},
 	}, testBotTokenHash)
-	if err != ErrInvalidHash {
-		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	if !errors.Is(err, ErrMissingHash) {
+		t.Errorf("expected ErrMissingHash, but was %v", err)
 	}
 }
 
@@ -127,4 +131,86 @@ func TestConvertAndVerifyForm_MarksMissingFields(t *testing.T) {
 	if u.Username != "" {
 		t.Errorf("username should be absent, but was %s", u.Username)
 	}
+	if u.Has(FieldFirstName) || u.Has(FieldLastName) || u.Has(FieldPhotoURL) || u.Has(FieldUsername) {
+		t.Errorf("no optional fields should be present, but were %b", u.Fields)
+	}
+}
+
+func TestConvertAndVerifyForm_WithUnknownField(t *testing.T) {
+	u, err := ConvertAndVerifyForm(url.Values{
+		"allows_write_to_pm": {"true"},
+		"auth_date":          {"1512345678"},
+		"id":                 {"12345678"},
+		"hash":               {"0fe0d3e050af6fb6989da883e585ca520d2a8e53ed7c9532d95385f2aaf83f73"},
+	}, testBotTokenHash)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if v := u.Extra["allows_write_to_pm"]; v != "true" {
+		t.Errorf("extra field allows_write_to_pm should be true, but was %v", v)
+	}
+}
+
+func TestConvertAndVerifyForm_WithUnsignedUnknownField(t *testing.T) {
+	_, err := ConvertAndVerifyForm(url.Values{
+		"auth_date": {"1512345678"},
+		"id":        {"12345678"},
+		"hash":      {"180f7d26839de06e6ecb26148f181553d24e1c62153400da55ae31483ee62ad3"},
+		"is_admin":  {"true"},
+	}, testBotTokenHash)
+	if err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyForm_WithShortHash(t *testing.T) {
+	_, err := ConvertAndVerifyForm(url.Values{
+		"auth_date": {"1512345678"},
+		"id":        {"12345678"},
+		"hash":      {"180f7d26"},
+	}, testBotTokenHash)
+	if !errors.Is(err, ErrBadHashEncoding) {
+		t.Errorf("expected ErrBadHashEncoding, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyForm_WithMalformedID(t *testing.T) {
+	_, err := ConvertAndVerifyForm(url.Values{
+		"auth_date": {"1512345678"},
+		"id":        {"john"},
+		"hash":      {"180f7d26839de06e6ecb26148f181553d24e1c62153400da55ae31483ee62ad3"},
+	}, testBotTokenHash)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "id" || fe.Value != "john" || !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected FieldError for id with ErrMalformed, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyForm_WithMultipleHashesCategory(t *testing.T) {
+	_, err := ConvertAndVerifyForm(url.Values{
+		"auth_date": {"1512345678"},
+		"id":        {"12345678"},
+		"hash":      {"180f7d26839de06e6ecb26148f181553d24e1c62153400da55ae31483ee62ad3", ""},
+	}, testBotTokenHash)
+	if !errors.Is(err, ErrNotSingleValue) {
+		t.Errorf("expected ErrNotSingleValue, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyForm_MarksEmptyFieldsPresent(t *testing.T) {
+	u, err := ConvertAndVerifyForm(url.Values{
+		"auth_date": {"1512345678"},
+		"id":        {"12345678"},
+		"username":  {""},
+		"hash":      {"2c03603d0416ae533288e0f7c4ce113301355a45463e7cc2016793170e756e04"},
+	}, testBotTokenHash)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if !u.Has(FieldUsername) {
+		t.Error("empty username should be present, but was absent")
+	}
+	if u.Has(FieldUsername | FieldFirstName) {
+		t.Error("first name should be absent, but was present")
+	}
 }
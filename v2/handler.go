@@ -0,0 +1,212 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultLoginMaxAge is how old user data that LoginHandler and JSONLoginHandler accept may be if they have no
+// Verifier.
+const DefaultLoginMaxAge = 24 * time.Hour
+
+// A LoginHandler handles the redirect from the Telegram login widget when it is configured with data-auth-url. It
+// verifies the user data in the query string, passes the User to OnLogin, and then redirects the browser onward.
+//
+// A LoginHandler must not be modified after it starts serving requests.
+type LoginHandler struct {
+	// TokenHash is the hashed bot token, as returned from HashBotToken.
+	TokenHash []byte
+
+	// Verifier, if not nil, verifies the user data, so that its MaxAge, ReplayStore and other settings apply. Its own
+	// TokenHash, MAC, TokenSource or KeyRing is used instead of the handler's TokenHash. If Verifier is nil, data older
+	// than DefaultLoginMaxAge is rejected, so that a leaked redirect URL doesn't log its user in forever.
+	Verifier *Verifier
+
+	// Resolver, if not nil, chooses the token hash for each request, in place of TokenHash and the TokenHash, MAC,
+	// TokenSource or KeyRing of Verifier. The rest of Verifier still applies.
+	Resolver BotResolver
+
+	// OnLogin is called with each verified user, typically to start a session. It may set headers and cookies on w,
+	// but must not write a body, as the handler redirects after it returns. If it returns an error, the error is
+	// passed to Error instead.
+	OnLogin func(w http.ResponseWriter, r *http.Request, u User) error
+
+	// RedirectParam is the name of a query parameter that holds where to send the browser after logging in. Telegram
+	// preserves the query string of data-auth-url, so a parameter added there comes back with the user data. Only
+	// local paths are followed; anything else is replaced with DefaultRedirect. If RedirectParam is empty, the browser
+	// is always sent to DefaultRedirect.
+	RedirectParam string
+
+	// SiteParams are the names of other query parameters that the site added to data-auth-url, such as a campaign or
+	// locale. Like RedirectParam, they come back with the user data but aren't signed by Telegram, so they are
+	// removed before verification. They remain available to OnLogin in r.URL.Query().
+	SiteParams []string
+
+	// DefaultRedirect is where to send the browser after logging in when there is no acceptable redirect parameter. If
+	// empty, "/" is used.
+	DefaultRedirect string
+
+	// Error writes the response when verification or OnLogin fails. If Error is nil, a plain text response is written
+	// with a status from ErrorStatus.
+	Error func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewLoginHandler returns a LoginHandler that verifies users with the hashed bot token and calls onLogin with them.
+func NewLoginHandler(tokenHash []byte, onLogin func(w http.ResponseWriter, r *http.Request, u User) error) *LoginHandler {
+	return &LoginHandler{TokenHash: tokenHash, OnLogin: onLogin}
+}
+
+func (h *LoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	f := r.URL.Query()
+	target := h.DefaultRedirect
+	if target == "" {
+		target = "/"
+	}
+	if h.RedirectParam != "" {
+		// The redirect parameter isn't from Telegram, so it isn't signed and mustn't be verified.
+		if next := f.Get(h.RedirectParam); isLocalRedirect(next) {
+			target = next
+		}
+		f.Del(h.RedirectParam)
+	}
+	for _, k := range h.SiteParams {
+		f.Del(k)
+	}
+
+	tokenHash, v, err := resolveBot(r, h.Resolver, h.TokenHash, h.Verifier)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	if v == nil {
+		v = &Verifier{TokenHash: tokenHash, MaxAge: DefaultLoginMaxAge}
+	}
+	u, err := v.ConvertAndVerifyForm(f)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	if h.OnLogin != nil {
+		if err := h.OnLogin(w, r, u); err != nil {
+			h.error(w, r, err)
+			return
+		}
+	}
+
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
+
+// Widget returns a copy of w that sends the user data to h, which must be served at authURL, so that the widget and
+// the handler are configured in one place. If next is not empty, it is added to authURL as RedirectParam so that h
+// sends the browser there after login. Any other query parameters in authURL must be listed in SiteParams, since h
+// would otherwise take them for user data and fail to verify it.
+func (h *LoginHandler) Widget(w Widget, authURL, next string) (Widget, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return Widget{}, fmt.Errorf("invalid auth URL: %v", err)
+	}
+	q := u.Query()
+	for k := range q {
+		if !h.isUnsignedParam(k) {
+			return Widget{}, fmt.Errorf("auth URL parameter %q is neither RedirectParam nor in SiteParams", k)
+		}
+	}
+	if next != "" {
+		if h.RedirectParam == "" {
+			return Widget{}, errors.New("a redirect requires RedirectParam to be set")
+		}
+		if !isLocalRedirect(next) {
+			return Widget{}, fmt.Errorf("redirect %q is not a local path", next)
+		}
+		q.Set(h.RedirectParam, next)
+		u.RawQuery = q.Encode()
+	}
+	w.AuthURL = u.String()
+	w.OnAuth = ""
+	return w, nil
+}
+
+// isUnsignedParam reports whether k is a query parameter that h removes before verification.
+func (h *LoginHandler) isUnsignedParam(k string) bool {
+	if h.RedirectParam != "" && k == h.RedirectParam {
+		return true
+	}
+	for _, p := range h.SiteParams {
+		if k == p {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *LoginHandler) error(w http.ResponseWriter, r *http.Request, err error) {
+	if h.Error != nil {
+		h.Error(w, r, err)
+		return
+	}
+	status := ErrorStatus(err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+// ErrorStatus returns the HTTP status code appropriate for an error returned by this package. Data that can't be
+// parsed is a bad request, data that can't be authenticated or is no longer acceptable is unauthorized, a failed CSRF
+// check is forbidden, data exceeding the Limits is too large, a body of the wrong type is unsupported, a request for
+// which no bot is configured is not found, and anything else is an internal server error.
+func ErrorStatus(err error) int {
+	var fe *FieldError
+	switch {
+	case errors.Is(err, ErrInvalidHash), errors.Is(err, ErrInvalidSignature), errors.Is(err, ErrExpired),
+		errors.Is(err, ErrFromFuture), errors.Is(err, ErrReplayed), errors.Is(err, ErrInvalidSession),
+		errors.Is(err, ErrSessionExpired), errors.Is(err, ErrInvalidToken), errors.Is(err, ErrTokenExpired):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrCSRF):
+		return http.StatusForbidden
+	case errors.Is(err, ErrTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrNotJSON):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, ErrUnknownBot):
+		return http.StatusNotFound
+	case errors.As(err, &fe), errors.Is(err, ErrMalformed):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// isLocalRedirect reports whether target is a path on the same site, so that redirecting to it can't send the browser
+// elsewhere.
+func isLocalRedirect(target string) bool {
+	// Browsers treat "//host" and "/\host" as references to another host.
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return false
+	}
+	u, err := url.Parse(target)
+	return err == nil && u.Scheme == "" && u.Host == ""
+}
@@ -0,0 +1,213 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func serveLogin(h http.Handler, q url.Values) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login?"+q.Encode(), nil))
+	return w
+}
+
+func withParam(q url.Values, k, v string) url.Values {
+	r := url.Values{}
+	for key, vs := range q {
+		r[key] = vs
+	}
+	r.Set(k, v)
+	return r
+}
+
+// newTestLoginHandler returns a LoginHandler that accepts testForm.
+func newTestLoginHandler(onLogin func(w http.ResponseWriter, r *http.Request, u User) error) *LoginHandler {
+	h := NewLoginHandler(testBotTokenHash, onLogin)
+	h.Verifier = testVerifier()
+	return h
+}
+
+func TestLoginHandler_WithValidCredentials(t *testing.T) {
+	var got User
+	h := newTestLoginHandler(func(w http.ResponseWriter, r *http.Request, u User) error {
+		got = u
+		return nil
+	})
+	w := serveLogin(h, testForm)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status should be 303, but was %d", w.Code)
+	}
+	if l := w.Header().Get("Location"); l != "/" {
+		t.Errorf("location should be /, but was %v", l)
+	}
+	if got.ID != 12345678 {
+		t.Errorf("OnLogin should be called with ID 12345678, but was %d", got.ID)
+	}
+}
+
+func TestLoginHandler_WithIncorrectHash(t *testing.T) {
+	called := false
+	h := newTestLoginHandler(func(w http.ResponseWriter, r *http.Request, u User) error {
+		called = true
+		return nil
+	})
+	w := serveLogin(h, withParam(testForm, "username", "mallory"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status should be 401, but was %d", w.Code)
+	}
+	if called {
+		t.Error("OnLogin should not be called")
+	}
+}
+
+func TestLoginHandler_WithoutVerifierRejectsOldData(t *testing.T) {
+	w := serveLogin(NewLoginHandler(testBotTokenHash, nil), testForm)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status should be 401, but was %d", w.Code)
+	}
+}
+
+func TestLoginHandler_WithLocalRedirect(t *testing.T) {
+	h := newTestLoginHandler(nil)
+	h.RedirectParam = "next"
+	w := serveLogin(h, withParam(testForm, "next", "/settings?tab=profile"))
+	if l := w.Header().Get("Location"); l != "/settings?tab=profile" {
+		t.Errorf("location should be /settings?tab=profile, but was %v", l)
+	}
+}
+
+func TestLoginHandler_WithForeignRedirect(t *testing.T) {
+	h := newTestLoginHandler(nil)
+	h.RedirectParam = "next"
+	h.DefaultRedirect = "/home"
+	for _, next := range []string{"https://evil.example/", "//evil.example/", "/\\evil.example/", "javascript:alert(1)"} {
+		w := serveLogin(h, withParam(testForm, "next", next))
+		if l := w.Header().Get("Location"); l != "/home" {
+			t.Errorf("location for %v should be /home, but was %v", next, l)
+		}
+	}
+}
+
+func TestLoginHandler_WithSiteParams(t *testing.T) {
+	var lang string
+	h := newTestLoginHandler(func(w http.ResponseWriter, r *http.Request, u User) error {
+		lang = r.URL.Query().Get("lang")
+		return nil
+	})
+	h.SiteParams = []string{"lang"}
+	w := serveLogin(h, withParam(testForm, "lang", "en"))
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("status should be 303, but was %d", w.Code)
+	}
+	if lang != "en" {
+		t.Errorf("OnLogin should see lang en, but was %q", lang)
+	}
+}
+
+func TestLoginHandler_WithUndeclaredSiteParam(t *testing.T) {
+	h := newTestLoginHandler(nil)
+	w := serveLogin(h, withParam(testForm, "lang", "en"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status should be 401, but was %d", w.Code)
+	}
+}
+
+func TestLoginHandler_Widget(t *testing.T) {
+	h := newTestLoginHandler(nil)
+	h.RedirectParam = "next"
+	h.SiteParams = []string{"lang"}
+	w, err := h.Widget(Widget{BotName: "samplebot", OnAuth: "f"}, "https://example.com/login?lang=en", "/settings")
+	if err != nil {
+		t.Fatalf("failed to derive widget: %v", err)
+	}
+	if w.OnAuth != "" {
+		t.Errorf("OnAuth should be cleared, but was %v", w.OnAuth)
+	}
+	u, err := url.Parse(w.AuthURL)
+	if err != nil {
+		t.Fatalf("failed to parse auth URL: %v", err)
+	}
+	q := u.Query()
+	for k, vs := range testForm {
+		q[k] = vs
+	}
+	rec := serveLogin(h, q)
+	if l := rec.Header().Get("Location"); rec.Code != http.StatusSeeOther || l != "/settings" {
+		t.Errorf("should redirect to /settings, but status was %d and location was %v", rec.Code, l)
+	}
+}
+
+func TestLoginHandler_WidgetWithInvalidOptions(t *testing.T) {
+	h := newTestLoginHandler(nil)
+	h.RedirectParam = "next"
+	for _, c := range []struct{ authURL, next string }{
+		{"https://example.com/login?lang=en", ""},
+		{"https://example.com/login", "https://evil.example/"},
+	} {
+		if _, err := h.Widget(Widget{BotName: "samplebot"}, c.authURL, c.next); err == nil {
+			t.Errorf("%+v should be invalid, but wasn't", c)
+		}
+	}
+	if _, err := newTestLoginHandler(nil).Widget(Widget{}, "/login", "/settings"); err == nil {
+		t.Error("redirect without RedirectParam should be invalid, but wasn't")
+	}
+}
+
+func TestLoginHandler_WithFailingOnLogin(t *testing.T) {
+	h := newTestLoginHandler(func(w http.ResponseWriter, r *http.Request, u User) error {
+		return errors.New("database unavailable")
+	})
+	var got error
+	h.Error = func(w http.ResponseWriter, r *http.Request, err error) {
+		got = err
+		w.WriteHeader(http.StatusTeapot)
+	}
+	w := serveLogin(h, testForm)
+	if w.Code != http.StatusTeapot || got == nil {
+		t.Errorf("custom error handler should be used, but status was %d and error was %v", w.Code, got)
+	}
+}
+
+func TestLoginHandler_WithPost(t *testing.T) {
+	h := newTestLoginHandler(nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/login?"+testForm.Encode(), nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("status should be 405, but was %d", w.Code)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	for _, c := range []struct {
+		err  error
+		want int
+	}{
+		{ErrInvalidHash, http.StatusUnauthorized},
+		{&AuthDateError{Err: ErrExpired}, http.StatusUnauthorized},
+		{newFieldError("id", "john", ErrMalformed, nil), http.StatusBadRequest},
+		{newFieldError("hash", "", ErrMissingHash, nil), http.StatusBadRequest},
+		{ErrNotJSON, http.StatusUnsupportedMediaType},
+		{errors.New("other"), http.StatusInternalServerError},
+	} {
+		if got := ErrorStatus(c.err); got != c.want {
+			t.Errorf("status for %v should be %d, but was %d", c.err, c.want, got)
+		}
+	}
+}
@@ -16,19 +16,19 @@ package telegramwidget
 
 import (
 	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/url"
+	"strconv"
 	"time"
 )
 
 // ConvertAndVerifyJSON accepts JSON from the provided reader and parses it into the returned User. The hash property of the
 // input JSON is used to validate the user data before it is returned.
 func ConvertAndVerifyJSON(r io.Reader, tokenHash []byte) (User, error) {
-	u, ps, expectedMAC, err := parseUserFromJSON(r)
+	u, ps, expectedMAC, err := parseUserFromJSON(r, DefaultLimits)
 	if err != nil {
 		return u, err
 	}
@@ -40,84 +40,157 @@ func ConvertAndVerifyJSON(r io.Reader, tokenHash []byte) (User, error) {
 	return u, nil
 }
 
-func parseUserFromJSON(r io.Reader) (User, []pair, []byte, error) {
+func parseUserFromJSON(r io.Reader, l Limits) (User, []pair, []byte, error) {
+	if l.MaxBytes <= 0 {
+		return decodeUserFromJSON(r, l)
+	}
+	lr := &limitedReader{r: r, n: l.MaxBytes}
+	u, ps, expectedMAC, err := decodeUserFromJSON(lr, l)
+	if lr.exceeded {
+		// Whatever error the decoder reported, the cause was the limit.
+		return u, nil, expectedMAC, l.tooManyBytes()
+	}
+	return u, ps, expectedMAC, err
+}
+
+func decodeUserFromJSON(r io.Reader, l Limits) (User, []pair, []byte, error) {
 	d := json.NewDecoder(r)
 	d.UseNumber()
 	var tu User
-	// There are six supported properties.
+	// There are six supported properties, but more may be present.
 	ps := make([]pair, 0, 6)
 	expectedMAC := make([]byte, sha256.Size)
+	hasHash := false
+	seen := make(map[string]bool)
 
 	if t, err := d.Token(); err == io.EOF {
-		return tu, nil, expectedMAC, fmt.Errorf("expected start of object, got EOF")
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected start of object, got EOF", ErrMalformed)
 	} else if err != nil {
-		return tu, nil, expectedMAC, fmt.Errorf("expected start of object, got error: %v", err)
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected start of object, got error: %v", ErrMalformed, err)
 	} else if d, ok := t.(json.Delim); !ok || d != '{' {
-		return tu, nil, expectedMAC, fmt.Errorf("expected start of object, got token: %v", t)
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected start of object, got token: %v", ErrMalformed, t)
 	}
 
 	for d.More() {
-		k, err := d.Token()
+		t, err := d.Token()
 		if err != nil {
-			return tu, nil, expectedMAC, fmt.Errorf("expected key, got error: %v", err)
-		} else if d, ok := k.(json.Delim); ok {
+			return tu, nil, expectedMAC, fmt.Errorf("%w: expected key, got error: %v", ErrMalformed, err)
+		}
+		k, ok := t.(string)
+		if !ok {
 			// This case should be impossible in well-formed JSON. We're inside an object, so keys should always be
 			// strings.
-			return tu, nil, expectedMAC, fmt.Errorf("expected key, got delimeter: %v", d)
+			return tu, nil, expectedMAC, fmt.Errorf("%w: expected key, got token: %v", ErrMalformed, t)
+		}
+		if seen[k] {
+			return tu, nil, expectedMAC, newFieldError(k, "", ErrNotSingleValue, nil)
+		}
+		seen[k] = true
+		if err := l.checkFieldCount(len(seen)); err != nil {
+			return tu, nil, expectedMAC, err
 		}
 
 		v, err := d.Token()
 		if err != nil {
-			return tu, nil, expectedMAC, fmt.Errorf("expected value, got error: %v", err)
+			return tu, nil, expectedMAC, fmt.Errorf("%w: expected value, got error: %v", ErrMalformed, err)
 		} else if _, ok := v.(json.Delim); ok {
-			return tu, nil, expectedMAC, fmt.Errorf("expected value, got delimeter: %v", v)
+			return tu, nil, expectedMAC, newFieldError(k, fmt.Sprint(v), ErrWrongType, nil)
+		}
+		switch v := v.(type) {
+		case string:
+			err = l.checkField(k, v)
+		case json.Number:
+			err = l.checkField(k, v.String())
+		}
+		if err != nil {
+			return tu, nil, expectedMAC, err
 		}
 
 		switch k {
 		case "id":
-			id := v.(json.Number)
+			id, err := jsonNumber(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"id", id.String()})
 			if tu.ID, err = id.Int64(); err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, id.String(), ErrMalformed, err)
 			}
 		case "first_name":
-			firstName := v.(string)
+			firstName, err := jsonString(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"first_name", firstName})
 			tu.FirstName = firstName
+			tu.Fields |= FieldFirstName
 		case "last_name":
-			lastName := v.(string)
+			lastName, err := jsonString(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"last_name", lastName})
 			tu.LastName = lastName
+			tu.Fields |= FieldLastName
 		case "username":
-			username := v.(string)
+			username, err := jsonString(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"username", username})
 			tu.Username = username
+			tu.Fields |= FieldUsername
 		case "photo_url":
-			photoURL := v.(string)
+			photoURL, err := jsonString(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"photo_url", photoURL})
 			if tu.PhotoURL, err = url.Parse(photoURL); err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, photoURL, ErrMalformed, err)
 			}
+			tu.Fields |= FieldPhotoURL
 		case "auth_date":
-			authDate := v.(json.Number)
+			authDate, err := jsonNumber(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
+			}
 			ps = append(ps, pair{"auth_date", authDate.String()})
 			// Fractional seconds are lost by this conversion.
 			seconds, err := authDate.Int64()
 			if err != nil {
-				return tu, nil, expectedMAC, err
+				return tu, nil, expectedMAC, newFieldError(k, authDate.String(), ErrMalformed, err)
 			}
 			tu.AuthDate = time.Unix(seconds, 0)
 		case "hash":
 			// This is only used to check validity, then is dropped.
-			hash := v.(string)
-			if hex.DecodedLen(len(hash)) != sha256.Size {
-				return tu, nil, expectedMAC, fmt.Errorf("hash must be 64 characters long, but wasn't")
+			hash, err := jsonString(k, v)
+			if err != nil {
+				return tu, nil, expectedMAC, err
 			}
-			if _, err := hex.Decode(expectedMAC, []byte(hash)); err != nil {
-				return tu, nil, expectedMAC, fmt.Errorf("failure to decode incoming hash: %v", err)
+			if err := decodeHash(hash, expectedMAC); err != nil {
+				return tu, nil, expectedMAC, err
 			}
+			hasHash = true
 		default:
-			log.Printf("unexpected field in Telegram user: %s", k)
+			// Telegram signs every field it sends, so fields added after this library was written must still be part
+			// of the check string.
+			var value string
+			switch v := v.(type) {
+			case json.Number:
+				value = v.String()
+			case string:
+				value = v
+			case bool:
+				value = strconv.FormatBool(v)
+			case nil:
+				value = "null"
+			}
+			ps = append(ps, pair{k, value})
+			if tu.Extra == nil {
+				tu.Extra = make(map[string]string)
+			}
+			tu.Extra[k] = value
 		}
 	}
 
@@ -125,20 +198,45 @@ func parseUserFromJSON(r io.Reader) (User, []pair, []byte, error) {
 	// The only way for d.More to return false is at the end of an object, and the end of an object should only be
 	// indicated by a '}' delimiter.
 	if t, err := d.Token(); err == io.EOF {
-		return tu, nil, expectedMAC, fmt.Errorf("expected end of object, got EOF")
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected end of object, got EOF", ErrMalformed)
 	} else if err != nil {
-		return tu, nil, expectedMAC, fmt.Errorf("expected end of object, got error: %v", err)
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected end of object, got error: %v", ErrMalformed, err)
 	} else if d, ok := t.(json.Delim); !ok || d != '}' {
-		return tu, nil, expectedMAC, fmt.Errorf("expected end of object, got token: %v", t)
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected end of object, got token: %v", ErrMalformed, t)
 	}
 
 	// A JSON document should only represent a single value, right? If so, the only possibility after closing the top
 	// level object is EOF.
 	if _, err := d.Token(); err == nil {
-		return tu, nil, expectedMAC, fmt.Errorf("expected EOF, but got a token")
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected EOF, but got a token", ErrMalformed)
 	} else if err != io.EOF {
-		return tu, nil, expectedMAC, fmt.Errorf("expected EOF, but got a different error: %v", err)
+		return tu, nil, expectedMAC, fmt.Errorf("%w: expected EOF, but got a different error: %v", ErrMalformed, err)
+	}
+
+	if !hasHash {
+		return tu, nil, expectedMAC, newFieldError("hash", "", ErrMissingHash, nil)
+	}
+	if err := checkRequired(ps, "id", "auth_date"); err != nil {
+		return tu, nil, expectedMAC, err
 	}
 
 	return tu, ps, expectedMAC, nil
 }
+
+// jsonString returns v if it is a string, or a *FieldError if it isn't.
+func jsonString(k string, v json.Token) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", newFieldError(k, fmt.Sprint(v), ErrWrongType, errors.New("expected string"))
+	}
+	return s, nil
+}
+
+// jsonNumber returns v if it is a number, or a *FieldError if it isn't. The decoder must be configured with UseNumber.
+func jsonNumber(k string, v json.Token) (json.Number, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return "", newFieldError(k, fmt.Sprint(v), ErrWrongType, errors.New("expected number"))
+	}
+	return n, nil
+}
@@ -15,6 +15,7 @@
 package telegramwidget
 
 import (
+	"errors"
 	"net/url"
 	"strings"
 	"testing"
@@ -58,6 +59,9 @@ func TestConvertAndVerifyJSON_WithValidCredentials(t *testing.T) {
 	if u.Username != "jsmith" {
 		t.Errorf("username should be jsmith, but was %s", u.Username)
 	}
+	if !u.Has(FieldFirstName | FieldLastName | FieldPhotoURL | FieldUsername) {
+		t.Errorf("all fields should be present, but were %b", u.Fields)
+	}
 }
 
 func TestConvertAndVerifyJSON_WithoutHash(t *testing.T) {
@@ -69,8 +73,8 @@ func TestConvertAndVerifyJSON_WithoutHash(t *testing.T) {
 		"photo_url": "https://t.me/i/userpic/320/jsmith.jpg",
 		"username": "jsmith"
 	}`), testBotTokenHash)
-	if err != ErrInvalidHash {
-		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	if !errors.Is(err, ErrMissingHash) {
+		t.Errorf("expected ErrMissingHash, but was %v", err)
 	}
 }
 
@@ -149,4 +153,64 @@ func TestConvertAndVerifyJSON_MarksMissingFields(t *testing.T) {
 	if u.Username != "" {
 		t.Errorf("username should be absent, but was %s", u.Username)
 	}
+	if u.Has(FieldFirstName) || u.Has(FieldLastName) || u.Has(FieldPhotoURL) || u.Has(FieldUsername) {
+		t.Errorf("no optional fields should be present, but were %b", u.Fields)
+	}
+}
+
+func TestConvertAndVerifyJSON_WithUnknownField(t *testing.T) {
+	u, err := ConvertAndVerifyJSON(strings.NewReader(`{
+		"allows_write_to_pm": true,
+		"auth_date": 1512345678,
+		"id": 12345678,
+		"hash": "0fe0d3e050af6fb6989da883e585ca520d2a8e53ed7c9532d95385f2aaf83f73"
+	}`), testBotTokenHash)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if v := u.Extra["allows_write_to_pm"]; v != "true" {
+		t.Errorf("extra field allows_write_to_pm should be true, but was %v", v)
+	}
+}
+
+func TestConvertAndVerifyJSON_WithStringID(t *testing.T) {
+	_, err := ConvertAndVerifyJSON(strings.NewReader(`{
+		"auth_date": 1512345678,
+		"id": "12345678",
+		"hash": "180f7d26839de06e6ecb26148f181553d24e1c62153400da55ae31483ee62ad3"
+	}`), testBotTokenHash)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "id" || !errors.Is(err, ErrWrongType) {
+		t.Errorf("expected FieldError for id with ErrWrongType, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyJSON_WithDuplicateKey(t *testing.T) {
+	_, err := ConvertAndVerifyJSON(strings.NewReader(`{
+		"auth_date": 1512345678,
+		"id": 12345678,
+		"id": 87654321,
+		"hash": "180f7d26839de06e6ecb26148f181553d24e1c62153400da55ae31483ee62ad3"
+	}`), testBotTokenHash)
+	if !errors.Is(err, ErrNotSingleValue) {
+		t.Errorf("expected ErrNotSingleValue, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyJSON_WithoutID(t *testing.T) {
+	_, err := ConvertAndVerifyJSON(strings.NewReader(`{
+		"auth_date": 1512345678,
+		"hash": "180f7d26839de06e6ecb26148f181553d24e1c62153400da55ae31483ee62ad3"
+	}`), testBotTokenHash)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "id" || !errors.Is(err, ErrMissingField) {
+		t.Errorf("expected FieldError for id with ErrMissingField, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyJSON_WithMalformedJSONCategory(t *testing.T) {
+	_, err := ConvertAndVerifyJSON(strings.NewReader("food"), testBotTokenHash)
+	if !errors.Is(err, ErrMalformed) {
+		t.Errorf("expected ErrMalformed, but was %v", err)
+	}
 }
@@ -0,0 +1,282 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken indicates that a JWT could not be parsed or its signature could not be verified.
+var ErrInvalidToken = errors.New("the token is invalid")
+
+// ErrTokenExpired indicates that a JWT was verified, but has expired or is not yet valid.
+var ErrTokenExpired = errors.New("the token has expired")
+
+// DefaultJWTTTL is how long tokens last if JWTIssuer.TTL is zero.
+const DefaultJWTTTL = time.Hour
+
+// MinJWTHMACKeySize is the shortest HMACKey that JWTIssuer and JWTVerifier accept, as RFC 7518 requires for HS256.
+const MinJWTHMACKeySize = 32
+
+// jwtClaims are the claims in tokens issued for a User. The Telegram-specific claims use the names from OpenID Connect.
+type jwtClaims struct {
+	Issuer            string `json:"iss,omitempty"`
+	Subject           string `json:"sub"`
+	Audience          string `json:"aud,omitempty"`
+	IssuedAt          int64  `json:"iat"`
+	Expires           int64  `json:"exp"`
+	AuthTime          int64  `json:"auth_time"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	GivenName         string `json:"given_name,omitempty"`
+	FamilyName        string `json:"family_name,omitempty"`
+	Picture           string `json:"picture,omitempty"`
+}
+
+type jwtHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ,omitempty"`
+	KeyID     string `json:"kid,omitempty"`
+}
+
+// A JWTIssuer issues JSON Web Tokens for verified users. Tokens are signed with HS256 if HMACKey is set, or with EdDSA
+// if PrivateKey is set. Exactly one of them must be set. HMACKey must be at least MinJWTHMACKeySize bytes.
+//
+// The subject of each token is the user's Telegram ID, and auth_time is their auth date.
+type JWTIssuer struct {
+	HMACKey    []byte
+	PrivateKey ed25519.PrivateKey
+
+	// KeyID, if not empty, is put in the kid header of each token.
+	KeyID string
+
+	// Issuer and Audience, if not empty, are put in the iss and aud claims of each token.
+	Issuer   string
+	Audience string
+
+	// TTL is how long each token is valid for. If TTL is zero, DefaultJWTTTL is used.
+	TTL time.Duration
+
+	// Now returns the current time. If Now is nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Issue returns a signed token for u.
+func (i *JWTIssuer) Issue(u User) (string, error) {
+	h := jwtHeader{Type: "JWT", KeyID: i.KeyID}
+	switch {
+	case len(i.HMACKey) > 0 && len(i.PrivateKey) == 0:
+		if err := checkJWTHMACKey(i.HMACKey); err != nil {
+			return "", err
+		}
+		h.Algorithm = "HS256"
+	case len(i.PrivateKey) > 0 && len(i.HMACKey) == 0:
+		// ed25519.Sign panics on a key of the wrong size.
+		if len(i.PrivateKey) != ed25519.PrivateKeySize {
+			return "", fmt.Errorf("PrivateKey is %d bytes, but must be %d", len(i.PrivateKey), ed25519.PrivateKeySize)
+		}
+		h.Algorithm = "EdDSA"
+	default:
+		return "", errors.New("exactly one of HMACKey and PrivateKey must be set")
+	}
+
+	ttl := i.TTL
+	if ttl == 0 {
+		ttl = DefaultJWTTTL
+	}
+	now := i.Now
+	if now == nil {
+		now = time.Now
+	}
+	iat := now()
+	c := jwtClaims{
+		Issuer:            i.Issuer,
+		Subject:           strconv.FormatInt(u.ID, 10),
+		Audience:          i.Audience,
+		IssuedAt:          iat.Unix(),
+		Expires:           iat.Add(ttl).Unix(),
+		AuthTime:          u.AuthDate.Unix(),
+		PreferredUsername: u.Username,
+		GivenName:         u.FirstName,
+		FamilyName:        u.LastName,
+	}
+	if u.PhotoURL != nil {
+		c.Picture = u.PhotoURL.String()
+	}
+
+	hj, err := json.Marshal(h)
+	if err != nil {
+		return "", err
+	}
+	cj, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(hj) + "." + base64.RawURLEncoding.EncodeToString(cj)
+
+	var sig []byte
+	if h.Algorithm == "HS256" {
+		mac := hmac.New(sha256.New, i.HMACKey)
+		mac.Write([]byte(signingInput))
+		sig = mac.Sum(nil)
+	} else {
+		sig = ed25519.Sign(i.PrivateKey, []byte(signingInput))
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// A JWTVerifier verifies tokens from a JWTIssuer and reconstructs the User they were issued for. Only the algorithm
+// matching the configured key is accepted: HS256 if HMACKey is set, or EdDSA if PublicKey is set. HMACKey must be at
+// least MinJWTHMACKeySize bytes.
+type JWTVerifier struct {
+	HMACKey   []byte
+	PublicKey ed25519.PublicKey
+
+	// Issuer and Audience, if not empty, must match the iss and aud claims of each token.
+	Issuer   string
+	Audience string
+
+	// Leeway allows for clock skew between the issuer and the verifier when checking expiry.
+	Leeway time.Duration
+
+	// Now returns the current time. If Now is nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Verify checks the signature and claims of token, and returns the User it was issued for.
+func (v *JWTVerifier) Verify(token string) (User, error) {
+	if err := v.checkKeys(); err != nil {
+		return User{}, err
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return User{}, ErrInvalidToken
+	}
+	hj, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return User{}, ErrInvalidToken
+	}
+	var h jwtHeader
+	if err := json.Unmarshal(hj, &h); err != nil {
+		return User{}, ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return User{}, ErrInvalidToken
+	}
+
+	signingInput := []byte(parts[0] + "." + parts[1])
+	switch {
+	case h.Algorithm == "HS256" && len(v.HMACKey) > 0:
+		mac := hmac.New(sha256.New, v.HMACKey)
+		mac.Write(signingInput)
+		if !hmac.Equal(sig, mac.Sum(nil)) {
+			return User{}, ErrInvalidToken
+		}
+	case h.Algorithm == "EdDSA" && len(v.PublicKey) > 0:
+		if !ed25519.Verify(v.PublicKey, signingInput, sig) {
+			return User{}, ErrInvalidToken
+		}
+	default:
+		// This includes "none", and an algorithm that doesn't match the configured key.
+		return User{}, ErrInvalidToken
+	}
+
+	cj, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return User{}, ErrInvalidToken
+	}
+	var c jwtClaims
+	if err := json.Unmarshal(cj, &c); err != nil {
+		return User{}, ErrInvalidToken
+	}
+	if (v.Issuer != "" && c.Issuer != v.Issuer) || (v.Audience != "" && c.Audience != v.Audience) {
+		return User{}, ErrInvalidToken
+	}
+
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	t := now()
+	if !t.Before(time.Unix(c.Expires, 0).Add(v.Leeway)) || t.Add(v.Leeway).Before(time.Unix(c.IssuedAt, 0)) {
+		return User{}, ErrTokenExpired
+	}
+
+	u := User{
+		AuthDate:  time.Unix(c.AuthTime, 0),
+		FirstName: c.GivenName,
+		LastName:  c.FamilyName,
+		Username:  c.PreferredUsername,
+	}
+	if u.ID, err = strconv.ParseInt(c.Subject, 10, 64); err != nil {
+		return User{}, ErrInvalidToken
+	}
+	if c.Picture != "" {
+		if u.PhotoURL, err = url.Parse(c.Picture); err != nil {
+			return User{}, ErrInvalidToken
+		}
+	}
+	// Empty claims are omitted, so presence can only be inferred from the values.
+	u.Fields = presentFields(u)
+	return u, nil
+}
+
+// checkKeys returns an error if v has no key, or a key that can't be used safely.
+func (v *JWTVerifier) checkKeys() error {
+	if len(v.HMACKey) == 0 && len(v.PublicKey) == 0 {
+		return errors.New("one of HMACKey and PublicKey must be set")
+	}
+	if len(v.HMACKey) > 0 {
+		if err := checkJWTHMACKey(v.HMACKey); err != nil {
+			return err
+		}
+	}
+	// ed25519.Verify panics on a key of the wrong size.
+	if len(v.PublicKey) > 0 && len(v.PublicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("PublicKey is %d bytes, but must be %d", len(v.PublicKey), ed25519.PublicKeySize)
+	}
+	return nil
+}
+
+func checkJWTHMACKey(key []byte) error {
+	if len(key) < MinJWTHMACKeySize {
+		return fmt.Errorf("HMACKey is %d bytes, but must be at least %d", len(key), MinJWTHMACKeySize)
+	}
+	return nil
+}
+
+// Authenticator returns an Authenticator that verifies tokens sent in a header of the form
+// "Authorization: Bearer <token>".
+func (v *JWTVerifier) Authenticator() Authenticator {
+	return func(r *http.Request) (User, error) {
+		token, ok := authorization(r, "Bearer")
+		if !ok {
+			return User{}, ErrNoCredentials
+		}
+		return v.Verify(token)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testJWTKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestJWT_RoundTripHS256(t *testing.T) {
+	now := fixedClock(testAuthDate.Add(time.Minute))
+	token, err := (&JWTIssuer{HMACKey: testJWTKey, Issuer: "example", Now: now}).Issue(testUser)
+	if err != nil {
+		t.Fatalf("failed to issue: %v", err)
+	}
+	u, err := (&JWTVerifier{HMACKey: testJWTKey, Issuer: "example", Now: now}).Verify(token)
+	if err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	if u.ID != testUser.ID || u.Username != testUser.Username || u.FirstName != testUser.FirstName ||
+		u.LastName != testUser.LastName || u.PhotoURL.String() != testUser.PhotoURL.String() {
+		t.Errorf("verified user should be %v, but was %v", testUser, u)
+	}
+	if !u.AuthDate.Equal(testAuthDate) {
+		t.Errorf("auth date should be %v, but was %v", testAuthDate, u.AuthDate)
+	}
+}
+
+func TestJWT_RoundTripEdDSA(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	now := fixedClock(testAuthDate.Add(time.Minute))
+	token, err := (&JWTIssuer{PrivateKey: priv, Now: now}).Issue(testUser)
+	if err != nil {
+		t.Fatalf("failed to issue: %v", err)
+	}
+	if _, err := (&JWTVerifier{PublicKey: priv.Public().(ed25519.PublicKey), Now: now}).Verify(token); err != nil {
+		t.Errorf("failed to verify: %v", err)
+	}
+}
+
+func TestJWT_HasStandardClaims(t *testing.T) {
+	token, _ := (&JWTIssuer{HMACKey: testJWTKey, Now: fixedClock(testAuthDate)}).Issue(testUser)
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	if err != nil {
+		t.Fatalf("failed to decode payload: %v", err)
+	}
+	var c map[string]interface{}
+	if err := json.Unmarshal(payload, &c); err != nil {
+		t.Fatalf("failed to unmarshal payload: %v", err)
+	}
+	if c["sub"] != "12345678" || c["preferred_username"] != "jsmith" || c["auth_time"] != float64(1512345678) ||
+		c["exp"] != float64(1512345678+3600) {
+		t.Errorf("unexpected claims %v", c)
+	}
+}
+
+func TestJWT_WithExpiredToken(t *testing.T) {
+	token, _ := (&JWTIssuer{HMACKey: testJWTKey, TTL: time.Minute, Now: fixedClock(testAuthDate)}).Issue(testUser)
+	v := JWTVerifier{HMACKey: testJWTKey, Now: fixedClock(testAuthDate.Add(2 * time.Minute))}
+	if _, err := v.Verify(token); err != ErrTokenExpired {
+		t.Errorf("expected ErrTokenExpired, but was %v", err)
+	}
+}
+
+func TestJWT_WithWrongAlgorithm(t *testing.T) {
+	priv := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	pub := priv.Public().(ed25519.PublicKey)
+	// A token signed with HS256 using the public key as the secret must not pass as EdDSA.
+	token, _ := (&JWTIssuer{HMACKey: pub, Now: fixedClock(testAuthDate)}).Issue(testUser)
+	v := JWTVerifier{PublicKey: pub, Now: fixedClock(testAuthDate)}
+	if _, err := v.Verify(token); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken, but was %v", err)
+	}
+}
+
+func TestJWT_WithNoneAlgorithm(t *testing.T) {
+	token, _ := (&JWTIssuer{HMACKey: testJWTKey, Now: fixedClock(testAuthDate)}).Issue(testUser)
+	parts := strings.Split(token, ".")
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+	v := JWTVerifier{HMACKey: testJWTKey, Now: fixedClock(testAuthDate)}
+	if _, err := v.Verify(unsigned); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken, but was %v", err)
+	}
+}
+
+func TestJWT_WithWrongAudience(t *testing.T) {
+	token, _ := (&JWTIssuer{HMACKey: testJWTKey, Audience: "a", Now: fixedClock(testAuthDate)}).Issue(testUser)
+	v := JWTVerifier{HMACKey: testJWTKey, Audience: "b", Now: fixedClock(testAuthDate)}
+	if _, err := v.Verify(token); err != ErrInvalidToken {
+		t.Errorf("expected ErrInvalidToken, but was %v", err)
+	}
+}
+
+func TestJWT_WithUnusableKeys(t *testing.T) {
+	now := fixedClock(testAuthDate)
+	for n, i := range []*JWTIssuer{
+		{HMACKey: []byte{}, Now: now},
+		{HMACKey: testJWTKey[:16], Now: now},
+		{PrivateKey: make(ed25519.PrivateKey, 10), Now: now},
+	} {
+		if _, err := i.Issue(testUser); err == nil {
+			t.Errorf("issuer %d should fail, but didn't", n)
+		}
+	}
+
+	token, err := (&JWTIssuer{HMACKey: testJWTKey, Now: now}).Issue(testUser)
+	if err != nil {
+		t.Fatalf("failed to issue: %v", err)
+	}
+	for n, v := range []*JWTVerifier{
+		{HMACKey: []byte{}, Now: now},
+		{HMACKey: testJWTKey[:16], Now: now},
+		{PublicKey: make(ed25519.PublicKey, 10), Now: now},
+	} {
+		if _, err := v.Verify(token); err == nil || err == ErrInvalidToken {
+			t.Errorf("verifier %d should report misconfiguration, but was %v", n, err)
+		}
+	}
+}
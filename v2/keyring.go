@@ -0,0 +1,111 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"io"
+	"net/url"
+	"time"
+)
+
+// A Key is a hashed bot token in a KeyRing.
+type Key struct {
+	// ID identifies the key. It is reported in User.KeyID when the key verifies data, so it should be unique within a
+	// KeyRing.
+	ID string
+
+	// TokenHash is the hashed bot token, as returned from HashBotToken. A key with an empty TokenHash never matches,
+	// since anyone could sign data with it.
+	TokenHash []byte
+
+	// NotBefore, if not zero, is when the key starts to be accepted.
+	NotBefore time.Time
+
+	// NotAfter, if not zero, is when the key stops being accepted.
+	NotAfter time.Time
+}
+
+// active reports whether k is accepted at now.
+func (k Key) active(now time.Time) bool {
+	return (k.NotBefore.IsZero() || !now.Before(k.NotBefore)) && (k.NotAfter.IsZero() || !now.After(k.NotAfter))
+}
+
+// A KeyRing verifies data against several bot tokens, such as those of separate bots, or the old and new tokens of a
+// bot while a revoked token is being replaced. Data is accepted if any key that is active at the time of verification
+// matches, and the ID of that key is reported in User.KeyID.
+//
+// Every active key is tried, whether or not an earlier one matched, so that the time taken doesn't reveal which key
+// matched. It still reveals how many keys are active.
+//
+// A KeyRing must not be modified after first use, but may then be used concurrently. It can be used on its own or as
+// the KeyRing of a Verifier.
+type KeyRing struct {
+	Keys []Key
+
+	// Now returns the current time, against which the keys' validity is checked. If Now is nil, time.Now is used.
+	// When the KeyRing is used by a Verifier, the Verifier's Now is used instead.
+	Now func() time.Time
+}
+
+// ConvertAndVerifyForm is like the package-level ConvertAndVerifyForm, but accepts data signed with any active key in
+// k and reports which in User.KeyID.
+func (k *KeyRing) ConvertAndVerifyForm(f url.Values) (User, error) {
+	u, ps, expectedMAC, err := parseUserFromForm(f, DefaultLimits)
+	if err != nil {
+		return u, err
+	}
+	return k.verify(u, ps, expectedMAC, k.now())
+}
+
+// ConvertAndVerifyJSON is like the package-level ConvertAndVerifyJSON, but accepts data signed with any active key in
+// k and reports which in User.KeyID.
+func (k *KeyRing) ConvertAndVerifyJSON(r io.Reader) (User, error) {
+	u, ps, expectedMAC, err := parseUserFromJSON(r, DefaultLimits)
+	if err != nil {
+		return u, err
+	}
+	return k.verify(u, ps, expectedMAC, k.now())
+}
+
+// verify sets u.KeyID to the ID of the key active at now that validates ps, or returns ErrInvalidHash if there is
+// none.
+func (k *KeyRing) verify(u User, ps []pair, expectedMAC []byte, now time.Time) (User, error) {
+	s := []byte(constructCheckString(ps))
+	matched := -1
+	for i, key := range k.Keys {
+		if !key.active(now) || len(key.TokenHash) == 0 {
+			continue
+		}
+		mac := hmac.New(sha256.New, key.TokenHash)
+		mac.Write(s)
+		// Select without branching on the result, so that a match takes as long as a mismatch.
+		matched = subtle.ConstantTimeSelect(subtle.ConstantTimeCompare(expectedMAC, mac.Sum(nil)), i, matched)
+	}
+	if matched < 0 {
+		return u, ErrInvalidHash
+	}
+	u.KeyID = k.Keys[matched].ID
+	return u, nil
+}
+
+func (k *KeyRing) now() time.Time {
+	if k.Now == nil {
+		return time.Now()
+	}
+	return k.Now()
+}
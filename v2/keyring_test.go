@@ -0,0 +1,114 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var otherBotTokenHash = HashBotToken(otherBotToken)
+
+func TestKeyRing_MatchesSecondKey(t *testing.T) {
+	k := KeyRing{Keys: []Key{
+		{ID: "staging", TokenHash: otherBotTokenHash},
+		{ID: "production", TokenHash: testBotTokenHash},
+	}}
+	u, err := k.ConvertAndVerifyForm(testForm)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if u.KeyID != "production" {
+		t.Errorf("key ID should be production, but was %q", u.KeyID)
+	}
+}
+
+func TestKeyRing_JSON(t *testing.T) {
+	k := KeyRing{Keys: []Key{{ID: "production", TokenHash: testBotTokenHash}}}
+	u, err := k.ConvertAndVerifyJSON(strings.NewReader(`{"auth_date":1512345678,"first_name":"John 🕶","hash":"25409759c10beb29bd3f3fe1d16ee0605ac82eb2907d886e196d481371b91501","id":12345678,"last_name":"Smith","photo_url":"https://t.me/i/userpic/320/jsmith.jpg","username":"jsmith"}`))
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if u.KeyID != "production" {
+		t.Errorf("key ID should be production, but was %q", u.KeyID)
+	}
+}
+
+func TestKeyRing_WithNoMatchingKey(t *testing.T) {
+	k := KeyRing{Keys: []Key{{ID: "staging", TokenHash: otherBotTokenHash}}}
+	if _, err := k.ConvertAndVerifyForm(testForm); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestKeyRing_WithEmptyKey(t *testing.T) {
+	f := withParam(testForm, "username", "mallory")
+	f.Del("hash")
+	forged, err := SignForm(f, nil)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	k := KeyRing{Keys: []Key{{ID: "misconfigured"}, {ID: "production", TokenHash: testBotTokenHash}}}
+	if _, err := k.ConvertAndVerifyForm(forged); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestKeyRing_WithInactiveKey(t *testing.T) {
+	rotated := testAuthDate.Add(time.Hour)
+	for _, tc := range []struct {
+		name string
+		key  Key
+		now  time.Time
+	}{
+		{"after NotAfter", Key{ID: "old", TokenHash: testBotTokenHash, NotAfter: rotated}, rotated.Add(time.Second)},
+		{"before NotBefore", Key{ID: "new", TokenHash: testBotTokenHash, NotBefore: rotated}, rotated.Add(-time.Second)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			k := KeyRing{Keys: []Key{tc.key}, Now: fixedClock(tc.now)}
+			if _, err := k.ConvertAndVerifyForm(testForm); err != ErrInvalidHash {
+				t.Errorf("expected ErrInvalidHash, but was %v", err)
+			}
+		})
+	}
+}
+
+func TestVerifier_WithKeyRing(t *testing.T) {
+	now := testAuthDate.Add(time.Minute)
+	v := Verifier{
+		// The TokenHash is ignored in favour of the KeyRing.
+		TokenHash: otherBotTokenHash,
+		KeyRing: &KeyRing{Keys: []Key{
+			{ID: "old", TokenHash: testBotTokenHash, NotAfter: now.Add(time.Hour)},
+			{ID: "new", TokenHash: otherBotTokenHash, NotBefore: now.Add(-time.Hour)},
+		}},
+		MaxAge: time.Hour,
+		Now:    fixedClock(now),
+	}
+	u, err := v.ConvertAndVerifyForm(testForm)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if u.KeyID != "old" {
+		t.Errorf("key ID should be old, but was %q", u.KeyID)
+	}
+
+	v.Now = fixedClock(now.Add(2 * time.Hour))
+	v.MaxAge = 0
+	if _, err := v.ConvertAndVerifyForm(testForm); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash after the old key expired, but was %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// ErrTooLarge indicates that the data received exceeded one of the Limits. It is checked before the data is
+// authenticated, so it says nothing about whether the data came from Telegram.
+var ErrTooLarge = errors.New("data exceeds size limits")
+
+// Limits bounds the size of data that will be parsed, so that an endpoint that accepts logins can't be made to do
+// unbounded work. A zero field means no limit.
+type Limits struct {
+	// MaxBytes is the largest encoded payload accepted.
+	MaxBytes int64
+
+	// MaxFields is the most fields accepted, including the hash.
+	MaxFields int
+
+	// MaxValueLength is the longest value accepted for any field, in characters.
+	MaxValueLength int
+
+	// MaxNameLength is the longest first name, last name or username accepted, in characters.
+	MaxNameLength int
+}
+
+// DefaultLimits are the limits used by ConvertAndVerifyForm, ConvertAndVerifyJSON and the other parsing functions, and
+// by a Verifier without its own Limits. They comfortably fit any data that Telegram sends. Telegram caps names at 64
+// characters.
+var DefaultLimits = Limits{
+	MaxBytes:       4096,
+	MaxFields:      32,
+	MaxValueLength: 1024,
+	MaxNameLength:  64,
+}
+
+func (l Limits) checkBytes(n int64) error {
+	if l.MaxBytes > 0 && n > l.MaxBytes {
+		return l.tooManyBytes()
+	}
+	return nil
+}
+
+func (l Limits) tooManyBytes() error {
+	return fmt.Errorf("%w: more than %d bytes", ErrTooLarge, l.MaxBytes)
+}
+
+func (l Limits) checkFieldCount(n int) error {
+	if l.MaxFields > 0 && n > l.MaxFields {
+		return fmt.Errorf("%w: more than %d fields", ErrTooLarge, l.MaxFields)
+	}
+	return nil
+}
+
+func (l Limits) checkField(k, v string) error {
+	max := l.MaxValueLength
+	switch k {
+	case "first_name", "last_name", "username":
+		if l.MaxNameLength > 0 && (max == 0 || l.MaxNameLength < max) {
+			max = l.MaxNameLength
+		}
+	}
+	// Counting characters is only necessary if the value might be within the limit.
+	if max > 0 && len(v) > max && utf8.RuneCountInString(v) > max {
+		// The value is left out, since it's too large to be useful in an error message.
+		return newFieldError(k, "", ErrTooLarge, fmt.Errorf("more than %d characters", max))
+	}
+	return nil
+}
+
+// limitedReader reads from r until more than n bytes have been read, after which it fails and records that the limit
+// was exceeded.
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		l.exceeded = true
+		return 0, ErrTooLarge
+	}
+	// Read one byte more than the limit so that exceeding it can be detected.
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		l.exceeded = true
+		return n, ErrTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestConvertAndVerifyJSON_WithLargeBody(t *testing.T) {
+	body := `{"auth_date": 1512345678, "padding": "` + strings.Repeat("x", 8192) + `"}`
+	if _, err := ConvertAndVerifyJSON(strings.NewReader(body), testBotTokenHash); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyJSON_WithLongName(t *testing.T) {
+	_, err := ConvertAndVerifyJSON(strings.NewReader(`{"first_name": "`+strings.Repeat("é", 65)+`"}`), testBotTokenHash)
+	var fe *FieldError
+	if !errors.As(err, &fe) || fe.Field != "first_name" || !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected FieldError for first_name with ErrTooLarge, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyJSON_WithMaximumLengthName(t *testing.T) {
+	// 64 characters is allowed, even though it's more than 64 bytes.
+	_, err := ConvertAndVerifyJSON(strings.NewReader(`{
+		"auth_date": 1512345678,
+		"first_name": "`+strings.Repeat("é", 64)+`",
+		"id": 12345678,
+		"hash": "180f7d26839de06e6ecb26148f181553d24e1c62153400da55ae31483ee62ad3"
+	}`), testBotTokenHash)
+	if err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyForm_WithManyFields(t *testing.T) {
+	f := url.Values{}
+	for i := 0; i < 100; i++ {
+		f.Set("field"+strconv.Itoa(i), "x")
+	}
+	if _, err := ConvertAndVerifyForm(f, testBotTokenHash); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyForm_WithLongValue(t *testing.T) {
+	f := url.Values{"photo_url": {"https://t.me/" + strings.Repeat("x", 2000)}}
+	if _, err := ConvertAndVerifyForm(f, testBotTokenHash); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, but was %v", err)
+	}
+}
+
+func TestVerifier_WithCustomLimits(t *testing.T) {
+	v := Verifier{TokenHash: testBotTokenHash, Limits: &Limits{MaxFields: 3}}
+	if _, err := v.ConvertAndVerifyForm(testForm); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, but was %v", err)
+	}
+
+	v = Verifier{TokenHash: testBotTokenHash, Limits: &Limits{}}
+	if _, err := v.ConvertAndVerifyForm(testForm); err != nil {
+		t.Errorf("failed to convert and verify without limits: %v", err)
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	lr := &limitedReader{r: strings.NewReader("abcdef"), n: 6}
+	if b, err := readAll(lr); err != nil || b != "abcdef" || lr.exceeded {
+		t.Errorf("reading exactly the limit should succeed, but read %q with error %v", b, err)
+	}
+
+	lr = &limitedReader{r: strings.NewReader("abcdefg"), n: 6}
+	if _, err := readAll(lr); err != ErrTooLarge || !lr.exceeded {
+		t.Errorf("expected ErrTooLarge, but was %v", err)
+	}
+}
+
+func readAll(lr *limitedReader) (string, error) {
+	var b strings.Builder
+	p := make([]byte, 4)
+	for {
+		n, err := lr.Read(p)
+		b.Write(p[:n])
+		if err != nil {
+			if err == io.EOF {
+				return b.String(), nil
+			}
+			return b.String(), err
+		}
+	}
+}
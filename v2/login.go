@@ -58,6 +58,10 @@ func constructCheckString(r []pair) string {
 }
 
 func validate(ps []pair, tokenHash []byte, expectedMAC []byte) bool {
+	// An empty key is never configured on purpose, and anyone could sign data with it.
+	if len(tokenHash) == 0 {
+		return false
+	}
 	s := constructCheckString(ps)
 	mac := hmac.New(sha256.New, tokenHash)
 	mac.Write([]byte(s))
@@ -66,6 +70,8 @@ func validate(ps []pair, tokenHash []byte, expectedMAC []byte) bool {
 	return hmac.Equal(expectedMAC, computedMAC)
 }
 
+// HashBotToken derives the key used to validate login widget data from a bot token. It accepts any string, so
+// ParseBotToken and BotToken.Hash should be preferred where the token comes from configuration.
 func HashBotToken(token string) []byte {
 	h := sha256.Sum256([]byte(token))
 	return h[:]
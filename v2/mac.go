@@ -0,0 +1,98 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// A MACComputer computes the HMAC-SHA-256 of a data-check-string with a key that the caller doesn't need to hold. It
+// lets the key stay in a separate process, such as a signing daemon, so that it never enters the memory of the process
+// verifying the data. The unixsigner package provides one that talks to a signer over a Unix socket.
+//
+// Implementations must be safe for concurrent use.
+type MACComputer interface {
+	MAC(checkString []byte) ([]byte, error)
+}
+
+// KeyMAC returns a MACComputer that computes MACs in process with key, such as a hashed bot token from HashBotToken. It
+// refuses to compute MACs if key is empty.
+func KeyMAC(key []byte) MACComputer {
+	return keyMAC(key)
+}
+
+type keyMAC []byte
+
+func (k keyMAC) MAC(checkString []byte) ([]byte, error) {
+	if len(k) == 0 {
+		return nil, errors.New("empty key")
+	}
+	mac := hmac.New(sha256.New, k)
+	mac.Write(checkString)
+	return mac.Sum(nil), nil
+}
+
+// TokenSourceMAC returns a MACComputer that computes MACs in process with the hash of the current token from s, so that
+// a signing daemon picks up a rotated token without restarting.
+func TokenSourceMAC(s TokenSource) MACComputer {
+	return tokenSourceMAC{s}
+}
+
+type tokenSourceMAC struct {
+	s TokenSource
+}
+
+func (m tokenSourceMAC) MAC(checkString []byte) ([]byte, error) {
+	t, err := m.s.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failure to get bot token: %w", err)
+	}
+	return keyMAC(t.Hash()).MAC(checkString)
+}
+
+// ConvertAndVerifyFormWith is like ConvertAndVerifyForm, but computes the expected hash with m instead of a key.
+func ConvertAndVerifyFormWith(f url.Values, m MACComputer) (User, error) {
+	u, ps, expectedMAC, err := parseUserFromForm(f, DefaultLimits)
+	if err != nil {
+		return u, err
+	}
+	return u, validateWith(ps, m, expectedMAC)
+}
+
+// ConvertAndVerifyJSONWith is like ConvertAndVerifyJSON, but computes the expected hash with m instead of a key.
+func ConvertAndVerifyJSONWith(r io.Reader, m MACComputer) (User, error) {
+	u, ps, expectedMAC, err := parseUserFromJSON(r, DefaultLimits)
+	if err != nil {
+		return u, err
+	}
+	return u, validateWith(ps, m, expectedMAC)
+}
+
+// validateWith is like validate, but uses m. It returns ErrInvalidHash if the MAC doesn't match, or an error from m.
+func validateWith(ps []pair, m MACComputer, expectedMAC []byte) error {
+	computedMAC, err := m.MAC([]byte(constructCheckString(ps)))
+	if err != nil {
+		return fmt.Errorf("failure to compute MAC: %w", err)
+	}
+	if !hmac.Equal(expectedMAC, computedMAC) {
+		return ErrInvalidHash
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingMAC struct{}
+
+var errSignerDown = errors.New("signer is down")
+
+func (failingMAC) MAC([]byte) ([]byte, error) {
+	return nil, errSignerDown
+}
+
+func TestConvertAndVerifyFormWith(t *testing.T) {
+	u, err := ConvertAndVerifyFormWith(testForm, KeyMAC(testBotTokenHash))
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if u.Username != "jsmith" {
+		t.Errorf("username should be jsmith, but was %v", u.Username)
+	}
+
+	if _, err := ConvertAndVerifyFormWith(testForm, KeyMAC(otherBotTokenHash)); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyJSONWith(t *testing.T) {
+	_, err := ConvertAndVerifyJSONWith(strings.NewReader(`{"auth_date":1512345678,"first_name":"John 🕶","hash":"25409759c10beb29bd3f3fe1d16ee0605ac82eb2907d886e196d481371b91501","id":12345678,"last_name":"Smith","photo_url":"https://t.me/i/userpic/320/jsmith.jpg","username":"jsmith"}`), KeyMAC(testBotTokenHash))
+	if err != nil {
+		t.Errorf("failed to convert and verify: %v", err)
+	}
+}
+
+func TestConvertAndVerifyFormWith_WithFailingMAC(t *testing.T) {
+	_, err := ConvertAndVerifyFormWith(testForm, failingMAC{})
+	if !errors.Is(err, errSignerDown) {
+		t.Errorf("expected the signer's error, but was %v", err)
+	}
+	if ErrorStatus(err) != http.StatusInternalServerError {
+		t.Errorf("status should be 500, but was %d", ErrorStatus(err))
+	}
+}
+
+func TestVerifier_WithMAC(t *testing.T) {
+	v := Verifier{
+		MAC:    KeyMAC(testBotTokenHash),
+		MaxAge: time.Hour,
+		Now:    fixedClock(testAuthDate.Add(time.Minute)),
+	}
+	if _, err := v.ConvertAndVerifyForm(testForm); err != nil {
+		t.Errorf("failed to convert and verify: %v", err)
+	}
+
+	v.MAC = failingMAC{}
+	if _, err := v.ConvertAndVerifyForm(testForm); !errors.Is(err, errSignerDown) {
+		t.Errorf("expected the signer's error, but was %v", err)
+	}
+
+	// The data is authenticated before its auth date is checked, so an unavailable signer can't be mistaken for expiry.
+	v.Now = fixedClock(testAuthDate.Add(2 * time.Hour))
+	if _, err := v.ConvertAndVerifyForm(testForm); !errors.Is(err, errSignerDown) {
+		t.Errorf("expected the signer's error, but was %v", err)
+	}
+}
+
+func TestKeyMAC_WithEmptyKey(t *testing.T) {
+	if _, err := ConvertAndVerifyFormWith(testForm, KeyMAC(nil)); err == nil || err == ErrInvalidHash {
+		t.Errorf("expected an error computing the MAC, but was %v", err)
+	}
+}
+
+func TestTokenSourceMAC(t *testing.T) {
+	tok, err := ParseBotToken(testBotToken)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if _, err := ConvertAndVerifyFormWith(testForm, TokenSourceMAC(StaticTokenSource(tok))); err != nil {
+		t.Errorf("failed to convert and verify: %v", err)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrNoCredentials indicates that a request didn't carry anything an Authenticator could verify.
+var ErrNoCredentials = errors.New("no credentials in request")
+
+// An Authenticator extracts and verifies a User from a request. If the request carries no credentials of the kind the
+// Authenticator understands, it returns ErrNoCredentials.
+type Authenticator func(r *http.Request) (User, error)
+
+// CookieAuthenticator returns an Authenticator that passes the value of the named cookie to decode. It is typically
+// used with a session cookie set by LoginHandler.OnLogin.
+func CookieAuthenticator(name string, decode func(value string) (User, error)) Authenticator {
+	return func(r *http.Request) (User, error) {
+		c, err := r.Cookie(name)
+		if err != nil {
+			return User{}, ErrNoCredentials
+		}
+		return decode(c.Value)
+	}
+}
+
+// DefaultWebAppMaxAge is how old initData that WebAppAuthenticator accepts may be if it is given no Verifier.
+const DefaultWebAppMaxAge = 24 * time.Hour
+
+// WebAppAuthenticator returns an Authenticator for requests from Telegram Mini Apps, which send their initData in a
+// header of the form "Authorization: tma <initData>". The secret key must be derived with WebAppSecretKey. If secretKey
+// is empty, the key is derived from the current token of v.TokenSource instead, and WebAppAuthenticator panics if v has
+// no TokenSource.
+//
+// The auth date of the initData is checked with v.CheckAuthDate. If v is nil, initData older than DefaultWebAppMaxAge
+// is rejected, since a Mini App sends the same initData with every request and a stolen copy would otherwise be usable
+// forever.
+//
+// The returned User is built from the user in the initData, with the auth date of the initData.
+func WebAppAuthenticator(secretKey []byte, v *Verifier) Authenticator {
+	if len(secretKey) == 0 && (v == nil || v.TokenSource == nil) {
+		panic("WebAppAuthenticator requires a secret key or a Verifier with a TokenSource")
+	}
+	if v == nil {
+		v = &Verifier{MaxAge: DefaultWebAppMaxAge}
+	}
+	return func(r *http.Request) (User, error) {
+		initData, ok := authorization(r, "tma")
+		if !ok {
+			return User{}, ErrNoCredentials
+		}
+		return v.verifyWebApp(initData, secretKey)
+	}
+}
+
+// verifyWebApp verifies initData with secretKey, or the key from v.TokenSource if secretKey is empty, checks its auth
+// date, and returns its user.
+func (v *Verifier) verifyWebApp(initData string, secretKey []byte) (User, error) {
+	key, err := v.webAppSecretKey(secretKey)
+	if err != nil {
+		return User{}, err
+	}
+	d, err := convertAndVerifyWebAppInitData(initData, key, v.limits())
+	if err != nil {
+		return User{}, err
+	}
+	if err := v.CheckAuthDate(d.AuthDate); err != nil {
+		return User{}, err
+	}
+	return webAppLoginUser(d)
+}
+
+// authorization returns the credentials from the Authorization header of r if they use the given scheme.
+func authorization(r *http.Request, scheme string) (string, bool) {
+	h := r.Header.Get("Authorization")
+	s, credentials, ok := strings.Cut(h, " ")
+	if !ok || !strings.EqualFold(s, scheme) {
+		return "", false
+	}
+	return strings.TrimSpace(credentials), true
+}
+
+// webAppLoginUser converts the user in Mini App initData to a User.
+func webAppLoginUser(d WebAppInitData) (User, error) {
+	if d.User == nil {
+		return User{}, newFieldError("user", "", ErrMissingField, nil)
+	}
+	u := User{
+		AuthDate:  d.AuthDate,
+		FirstName: d.User.FirstName,
+		ID:        d.User.ID,
+		LastName:  d.User.LastName,
+		Username:  d.User.Username,
+	}
+	if d.User.PhotoURL != "" {
+		var err error
+		if u.PhotoURL, err = url.Parse(d.User.PhotoURL); err != nil {
+			return User{}, newFieldError("user", d.User.PhotoURL, ErrMalformed, err)
+		}
+	}
+	// Mini Apps omit empty fields, so presence can only be inferred from the values.
+	u.Fields = presentFields(u)
+	return u, nil
+}
+
+// An UnauthenticatedPolicy decides what happens to requests that Middleware couldn't authenticate. It wraps the
+// handler that authenticated requests go to.
+type UnauthenticatedPolicy func(next http.Handler) http.Handler
+
+// Reject is an UnauthenticatedPolicy that responds 401 Unauthorized.
+func Reject(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// PassThrough is an UnauthenticatedPolicy that serves the request anyway, without a User in its context. It suits
+// pages that work for both anonymous and logged in users.
+func PassThrough(next http.Handler) http.Handler {
+	return next
+}
+
+// RedirectToLogin returns an UnauthenticatedPolicy that redirects the browser to the login page at loginURL.
+func RedirectToLogin(loginURL string) UnauthenticatedPolicy {
+	return func(next http.Handler) http.Handler {
+		return http.RedirectHandler(loginURL, http.StatusSeeOther)
+	}
+}
+
+// Middleware authenticates requests and stores the User in the request context, where handlers can retrieve it with
+// UserFromContext.
+//
+// A Middleware must not be modified after its Handler method is first called.
+type Middleware struct {
+	// Authenticators are tried in order. The first one that doesn't return ErrNoCredentials decides whether the request
+	// is authenticated.
+	Authenticators []Authenticator
+
+	// Unauthenticated decides what happens to requests that couldn't be authenticated. If it is nil, Reject is used.
+	Unauthenticated UnauthenticatedPolicy
+}
+
+// Handler returns a handler that authenticates requests before passing them to next.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	policy := m.Unauthenticated
+	if policy == nil {
+		policy = Reject
+	}
+	unauthenticated := policy(next)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := m.authenticate(r)
+		if err != nil {
+			unauthenticated.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(NewContext(r.Context(), u)))
+	})
+}
+
+func (m *Middleware) authenticate(r *http.Request) (User, error) {
+	for _, a := range m.Authenticators {
+		u, err := a(r)
+		if err == ErrNoCredentials {
+			continue
+		}
+		return u, err
+	}
+	return User{}, ErrNoCredentials
+}
+
+type userContextKey struct{}
+
+// NewContext returns a copy of ctx that carries u.
+func NewContext(ctx context.Context, u User) context.Context {
+	return context.WithValue(ctx, userContextKey{}, u)
+}
+
+// UserFromContext returns the User stored in ctx by Middleware or NewContext, if there is one.
+func UserFromContext(ctx context.Context) (User, bool) {
+	u, ok := ctx.Value(userContextKey{}).(User)
+	return u, ok
+}
+
+// presentFields returns the optional fields of u that have non-zero values.
+func presentFields(u User) Fields {
+	var f Fields
+	if u.FirstName != "" {
+		f |= FieldFirstName
+	}
+	if u.LastName != "" {
+		f |= FieldLastName
+	}
+	if u.PhotoURL != nil {
+		f |= FieldPhotoURL
+	}
+	if u.Username != "" {
+		f |= FieldUsername
+	}
+	return f
+}
@@ -0,0 +1,146 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var testInitData = url.Values{
+	"auth_date": {"1512345678"},
+	"hash":      {"11a0a5b7e2e2473efff79464d4bef0789e06828053dfffe369df9d78543a7707"},
+	"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+	"user":      {testWebAppUser},
+}.Encode()
+
+// testWebAppVerifier accepts testInitData.
+var testWebAppVerifier = &Verifier{MaxAge: time.Hour, Now: fixedClock(testAuthDate.Add(time.Minute))}
+
+// recordUser returns a handler that records the User in the request context.
+func recordUser(u *User, ok *bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*u, *ok = UserFromContext(r.Context())
+	})
+}
+
+func TestMiddleware_WithWebAppHeader(t *testing.T) {
+	m := Middleware{Authenticators: []Authenticator{WebAppAuthenticator(WebAppSecretKey(testBotToken), testWebAppVerifier)}}
+	var u User
+	var ok bool
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "tma "+testInitData)
+	w := httptest.NewRecorder()
+	m.Handler(recordUser(&u, &ok)).ServeHTTP(w, r)
+	if !ok {
+		t.Fatalf("user should be in context, but wasn't; status was %d", w.Code)
+	}
+	if u.ID != 12345678 || u.Username != "jsmith" {
+		t.Errorf("user should be 12345678 jsmith, but was %d %s", u.ID, u.Username)
+	}
+}
+
+func TestMiddleware_WithCookie(t *testing.T) {
+	m := Middleware{Authenticators: []Authenticator{
+		WebAppAuthenticator(WebAppSecretKey(testBotToken), testWebAppVerifier),
+		CookieAuthenticator("session", func(v string) (User, error) {
+			if v != "good" {
+				return User{}, errors.New("bad session")
+			}
+			return User{ID: 42}, nil
+		}),
+	}}
+	var u User
+	var ok bool
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "session", Value: "good"})
+	m.Handler(recordUser(&u, &ok)).ServeHTTP(httptest.NewRecorder(), r)
+	if !ok || u.ID != 42 {
+		t.Errorf("user 42 should be in context, but was %v, %v", u, ok)
+	}
+}
+
+func TestMiddleware_RejectsByDefault(t *testing.T) {
+	m := Middleware{Authenticators: []Authenticator{WebAppAuthenticator(WebAppSecretKey(testBotToken), testWebAppVerifier)}}
+	var u User
+	var ok bool
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "tma "+testInitData+"&start_param=injected")
+	w := httptest.NewRecorder()
+	m.Handler(recordUser(&u, &ok)).ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status should be 401, but was %d", w.Code)
+	}
+	if ok {
+		t.Error("next handler should not be called")
+	}
+}
+
+func TestWebAppAuthenticator_WithoutVerifierRejectsOldData(t *testing.T) {
+	a := WebAppAuthenticator(WebAppSecretKey(testBotToken), nil)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "tma "+testInitData)
+	if _, err := a(r); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired, but was %v", err)
+	}
+}
+
+func TestWebAppAuthenticator_WithoutKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic without a key, but there was none")
+		}
+	}()
+	WebAppAuthenticator(nil, nil)
+}
+
+func TestWebAppAuthenticator_WithoutKeyOrTokenSource(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic without a key or TokenSource, but there was none")
+		}
+	}()
+	WebAppAuthenticator(nil, &Verifier{MaxAge: time.Hour})
+}
+
+func TestMiddleware_WithRedirectToLogin(t *testing.T) {
+	m := Middleware{Unauthenticated: RedirectToLogin("/login")}
+	var u User
+	var ok bool
+	w := httptest.NewRecorder()
+	m.Handler(recordUser(&u, &ok)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if l := w.Header().Get("Location"); w.Code != http.StatusSeeOther || l != "/login" {
+		t.Errorf("should redirect to /login, but status was %d and location was %v", w.Code, l)
+	}
+}
+
+func TestMiddleware_WithPassThrough(t *testing.T) {
+	m := Middleware{Unauthenticated: PassThrough}
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		if _, ok := UserFromContext(r.Context()); ok {
+			t.Error("user should not be in context")
+		}
+	})
+	m.Handler(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !called {
+		t.Error("next handler should be called")
+	}
+}
@@ -30,3 +30,31 @@ func estimateSize(ps []pair) int {
 	}
 	return s
 }
+
+// withoutKey returns the pairs in ps other than those with the given key. The backing array of ps is reused.
+func withoutKey(ps []pair, key string) []pair {
+	r := ps[:0]
+	for _, p := range ps {
+		if p.key != key {
+			r = append(r, p)
+		}
+	}
+	return r
+}
+
+// checkRequired returns a *FieldError if any of the keys is missing from ps.
+func checkRequired(ps []pair, keys ...string) error {
+	for _, k := range keys {
+		found := false
+		for _, p := range ps {
+			if p.key == k {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return newFieldError(k, "", ErrMissingField, nil)
+		}
+	}
+	return nil
+}
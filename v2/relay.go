@@ -0,0 +1,259 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrCSRF indicates that a request to JSONLoginHandler didn't carry a CSRF token matching its CSRF cookie.
+var ErrCSRF = errors.New("missing or mismatched CSRF token")
+
+// ErrNotJSON indicates that a request to JSONLoginHandler didn't have a JSON body.
+var ErrNotJSON = errors.New("request body is not JSON")
+
+// CSRFHeader is the request header that the relay script sends the CSRF token in.
+const CSRFHeader = "X-CSRF-Token"
+
+// DefaultCSRFCookieName is the name of the CSRF cookie if JSONLoginHandler.CSRFCookieName is empty. The __Host- prefix
+// stops the cookie from being set by other subdomains. Browsers only accept it over HTTPS, so Insecure uses the name
+// without the prefix.
+const DefaultCSRFCookieName = "__Host-telegram_csrf"
+
+// DefaultMaxBodyBytes is the largest request body JSONLoginHandler accepts if MaxBodyBytes is zero.
+const DefaultMaxBodyBytes = 4096
+
+// relayScript is served by JSONLoginHandler.RelayScript. It reads its configuration from the attributes of its script
+// tag, defines the widget's data-onauth callback, and posts the user data to the login endpoint along with the CSRF
+// token from the CSRF cookie. The script contains no secrets, so it doesn't matter if another site includes it.
+const relayScript = `(function () {
+  'use strict';
+  var script = document.currentScript;
+  var endpoint = script.getAttribute('data-endpoint');
+  var redirect = script.getAttribute('data-redirect');
+  var callback = script.getAttribute('data-callback') || 'onTelegramAuth';
+  var cookieName = %s;
+  function csrfToken() {
+    var cookies = document.cookie.split(';');
+    for (var i = 0; i < cookies.length; i++) {
+      var c = cookies[i].trim();
+      if (c.substring(0, cookieName.length + 1) === cookieName + '=') {
+        return decodeURIComponent(c.substring(cookieName.length + 1));
+      }
+    }
+    return '';
+  }
+  window[callback] = function (user) {
+    fetch(endpoint, {
+      method: 'POST',
+      credentials: 'same-origin',
+      headers: {'Content-Type': 'application/json', 'X-CSRF-Token': csrfToken()},
+      body: JSON.stringify(user)
+    }).then(function (response) {
+      if (!response.ok) {
+        throw new Error('Telegram login failed with status ' + response.status);
+      }
+      if (redirect) {
+        window.location.assign(redirect);
+      }
+    }).catch(function (e) {
+      console.error(e);
+    });
+  };
+})();
+`
+
+// A JSONLoginHandler receives the user data that the login widget passes to its data-onauth callback. The data is
+// posted by a relay script, served by the handler's RelayScript method, which protects the endpoint from login CSRF
+// with a double-submit cookie: the script sends the value of a cookie that only pages on the same site can read, and
+// the handler checks that it matches the cookie.
+//
+// To use it, serve RelayScript and the handler itself, and include the script on the login page after the widget:
+//
+//	<script src="/telegram/relay.js" data-endpoint="/telegram/login" data-redirect="/"></script>
+//
+// The widget's OnAuth should be "onTelegramAuth", or the value of the script's data-callback attribute.
+//
+// A JSONLoginHandler must not be modified after it starts serving requests.
+type JSONLoginHandler struct {
+	// TokenHash is the hashed bot token, as returned from HashBotToken.
+	TokenHash []byte
+
+	// Verifier, if not nil, verifies the user data, so that its MaxAge, ReplayStore and other settings apply. Its own
+	// TokenHash, MAC, TokenSource or KeyRing is used instead of the handler's TokenHash. If Verifier is nil, data older
+	// than DefaultLoginMaxAge is rejected.
+	Verifier *Verifier
+
+	// Resolver, if not nil, chooses the token hash for each request, in place of TokenHash and the TokenHash, MAC,
+	// TokenSource or KeyRing of Verifier. The rest of Verifier still applies.
+	Resolver BotResolver
+
+	// OnLogin is called with each verified user, typically to start a session. It may set headers and cookies on w,
+	// but must not write a body. If it returns an error, the error is passed to Error instead.
+	OnLogin func(w http.ResponseWriter, r *http.Request, u User) error
+
+	// MaxBodyBytes is the largest request body accepted. If MaxBodyBytes is zero, DefaultMaxBodyBytes is used.
+	MaxBodyBytes int64
+
+	// CSRFCookieName is the name of the CSRF cookie. If CSRFCookieName is empty, DefaultCSRFCookieName is used.
+	CSRFCookieName string
+
+	// Insecure allows the CSRF cookie to be sent over plain HTTP. It should only be set in development.
+	Insecure bool
+
+	// Error writes the response when a request is rejected or OnLogin fails. If Error is nil, a plain text response
+	// is written with a status from ErrorStatus.
+	Error func(w http.ResponseWriter, r *http.Request, err error)
+}
+
+// NewJSONLoginHandler returns a JSONLoginHandler that verifies users with the hashed bot token and calls onLogin with
+// them.
+func NewJSONLoginHandler(tokenHash []byte, onLogin func(w http.ResponseWriter, r *http.Request, u User) error) *JSONLoginHandler {
+	return &JSONLoginHandler{TokenHash: tokenHash, OnLogin: onLogin}
+}
+
+// ServeHTTP verifies user data posted by the relay script. It responds 204 No Content on success.
+func (h *JSONLoginHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.checkCSRF(r) {
+		h.error(w, r, ErrCSRF)
+		return
+	}
+
+	if mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mt != "application/json" {
+		h.error(w, r, ErrNotJSON)
+		return
+	}
+
+	maxBytes := h.MaxBodyBytes
+	if maxBytes == 0 {
+		maxBytes = DefaultMaxBodyBytes
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+	if err != nil {
+		// Anything other than an oversized body is a broken or abandoned upload.
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			err = fmt.Errorf("%w: %v", ErrTooLarge, err)
+		} else {
+			err = fmt.Errorf("%w: failure to read request body: %v", ErrMalformed, err)
+		}
+		h.error(w, r, err)
+		return
+	}
+
+	tokenHash, v, err := resolveBot(r, h.Resolver, h.TokenHash, h.Verifier)
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	if v == nil {
+		v = &Verifier{TokenHash: tokenHash, MaxAge: DefaultLoginMaxAge}
+	}
+	u, err := v.ConvertAndVerifyJSON(bytes.NewReader(body))
+	if err != nil {
+		h.error(w, r, err)
+		return
+	}
+
+	if h.OnLogin != nil {
+		if err := h.OnLogin(w, r, u); err != nil {
+			h.error(w, r, err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RelayScript returns a handler that serves the relay script, and sets the CSRF cookie if the browser doesn't have
+// one yet.
+func (h *JSONLoginHandler) RelayScript() http.Handler {
+	name, _ := json.Marshal(h.csrfCookieName())
+	script := []byte(strings.Replace(relayScript, "%s", string(name), 1))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie(h.csrfCookieName()); err != nil || c.Value == "" {
+			token, err := newCSRFToken()
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:   h.csrfCookieName(),
+				Value:  token,
+				Path:   "/",
+				Secure: !h.Insecure,
+				// The relay script has to read the cookie, so it can't be HttpOnly.
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+		w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(script)
+	})
+}
+
+func (h *JSONLoginHandler) checkCSRF(r *http.Request) bool {
+	c, err := r.Cookie(h.csrfCookieName())
+	if err != nil || c.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.Header.Get(CSRFHeader))) == 1
+}
+
+func (h *JSONLoginHandler) csrfCookieName() string {
+	switch {
+	case h.CSRFCookieName != "":
+		return h.CSRFCookieName
+	case h.Insecure:
+		return strings.TrimPrefix(DefaultCSRFCookieName, "__Host-")
+	default:
+		return DefaultCSRFCookieName
+	}
+}
+
+func (h *JSONLoginHandler) error(w http.ResponseWriter, r *http.Request, err error) {
+	if h.Error != nil {
+		h.Error(w, r, err)
+		return
+	}
+	status := ErrorStatus(err)
+	http.Error(w, http.StatusText(status), status)
+}
+
+func newCSRFToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,185 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+const testUserJSON = `{
+	"auth_date": 1512345678,
+	"first_name": "John 🕶",
+	"hash": "25409759c10beb29bd3f3fe1d16ee0605ac82eb2907d886e196d481371b91501",
+	"id": 12345678,
+	"last_name": "Smith",
+	"photo_url": "https://t.me/i/userpic/320/jsmith.jpg",
+	"username": "jsmith"
+}`
+
+// newTestJSONLoginHandler returns a JSONLoginHandler that accepts testUserJSON.
+func newTestJSONLoginHandler(onLogin func(w http.ResponseWriter, r *http.Request, u User) error) *JSONLoginHandler {
+	h := NewJSONLoginHandler(testBotTokenHash, onLogin)
+	h.Verifier = testVerifier()
+	return h
+}
+
+// csrfCookie fetches the relay script from h and returns the CSRF cookie it sets.
+func csrfCookie(t *testing.T, h *JSONLoginHandler) *http.Cookie {
+	w := httptest.NewRecorder()
+	h.RelayScript().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/relay.js", nil))
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/javascript") {
+		t.Errorf("content type should be text/javascript, but was %v", ct)
+	}
+	if !strings.Contains(w.Body.String(), `"__Host-telegram_csrf"`) {
+		t.Errorf("script should contain the cookie name, but was %v", w.Body.String())
+	}
+	cs := w.Result().Cookies()
+	if len(cs) != 1 {
+		t.Fatalf("should set one cookie, but set %d", len(cs))
+	}
+	return cs[0]
+}
+
+func postJSON(h http.Handler, body string, c *http.Cookie, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	if c != nil {
+		r.AddCookie(c)
+	}
+	if token != "" {
+		r.Header.Set(CSRFHeader, token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestJSONLoginHandler_WithValidRequest(t *testing.T) {
+	var got User
+	h := newTestJSONLoginHandler(func(w http.ResponseWriter, r *http.Request, u User) error {
+		got = u
+		return nil
+	})
+	c := csrfCookie(t, h)
+	if w := postJSON(h, testUserJSON, c, c.Value); w.Code != http.StatusNoContent {
+		t.Fatalf("status should be 204, but was %d", w.Code)
+	}
+	if got.ID != 12345678 {
+		t.Errorf("OnLogin should be called with ID 12345678, but was %d", got.ID)
+	}
+}
+
+func TestJSONLoginHandler_WithoutVerifierRejectsOldData(t *testing.T) {
+	h := NewJSONLoginHandler(testBotTokenHash, nil)
+	c := csrfCookie(t, h)
+	if w := postJSON(h, testUserJSON, c, c.Value); w.Code != http.StatusUnauthorized {
+		t.Errorf("status should be 401, but was %d", w.Code)
+	}
+}
+
+func TestJSONLoginHandler_WithoutCSRFToken(t *testing.T) {
+	h := newTestJSONLoginHandler(nil)
+	c := csrfCookie(t, h)
+	if w := postJSON(h, testUserJSON, c, ""); w.Code != http.StatusForbidden {
+		t.Errorf("status should be 403, but was %d", w.Code)
+	}
+	if w := postJSON(h, testUserJSON, nil, c.Value); w.Code != http.StatusForbidden {
+		t.Errorf("status without cookie should be 403, but was %d", w.Code)
+	}
+	if w := postJSON(h, testUserJSON, c, c.Value+"x"); w.Code != http.StatusForbidden {
+		t.Errorf("status with wrong token should be 403, but was %d", w.Code)
+	}
+}
+
+func TestJSONLoginHandler_WithWrongContentType(t *testing.T) {
+	h := newTestJSONLoginHandler(nil)
+	c := csrfCookie(t, h)
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(testUserJSON))
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Set(CSRFHeader, c.Value)
+	r.AddCookie(c)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("status should be 415, but was %d", w.Code)
+	}
+}
+
+func TestJSONLoginHandler_WithLargeBody(t *testing.T) {
+	h := newTestJSONLoginHandler(nil)
+	h.MaxBodyBytes = 64
+	c := csrfCookie(t, h)
+	if w := postJSON(h, testUserJSON, c, c.Value); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status should be 413, but was %d", w.Code)
+	}
+}
+
+func TestJSONLoginHandler_WithBrokenBody(t *testing.T) {
+	h := newTestJSONLoginHandler(nil)
+	c := csrfCookie(t, h)
+	r := httptest.NewRequest(http.MethodPost, "/login", iotest.ErrReader(io.ErrUnexpectedEOF))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set(CSRFHeader, c.Value)
+	r.AddCookie(c)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status should be 400, but was %d", w.Code)
+	}
+}
+
+func TestJSONLoginHandler_WithErrorHandler(t *testing.T) {
+	h := newTestJSONLoginHandler(nil)
+	var got error
+	h.Error = func(w http.ResponseWriter, r *http.Request, err error) {
+		got = err
+		w.WriteHeader(http.StatusTeapot)
+	}
+	c := csrfCookie(t, h)
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(testUserJSON))
+	r.Header.Set("Content-Type", "text/plain")
+	r.Header.Set(CSRFHeader, c.Value)
+	r.AddCookie(c)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusTeapot || got != ErrNotJSON {
+		t.Errorf("Error should be called with ErrNotJSON, but status was %d and error was %v", w.Code, got)
+	}
+
+	h.MaxBodyBytes = 64
+	got = nil
+	w = postJSON(h, testUserJSON, c, c.Value)
+	if w.Code != http.StatusTeapot || !errors.Is(got, ErrTooLarge) {
+		t.Errorf("Error should be called with ErrTooLarge, but status was %d and error was %v", w.Code, got)
+	}
+}
+
+func TestJSONLoginHandler_KeepsExistingCSRFCookie(t *testing.T) {
+	h := newTestJSONLoginHandler(nil)
+	c := csrfCookie(t, h)
+	r := httptest.NewRequest(http.MethodGet, "/relay.js", nil)
+	r.AddCookie(c)
+	w := httptest.NewRecorder()
+	h.RelayScript().ServeHTTP(w, r)
+	if cs := w.Result().Cookies(); len(cs) != 0 {
+		t.Errorf("should not replace existing cookie, but set %v", cs)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"container/list"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrReplayed indicates that the data received was authenticated, but has already been used.
+var ErrReplayed = errors.New("the data has already been used")
+
+// A ReplayStore remembers keys for a limited time. Implementations must be safe for concurrent use.
+type ReplayStore interface {
+	// Add records key until expires, and reports whether it was added. If key is already recorded and hasn't expired,
+	// Add returns false. A zero expires means the key never expires.
+	Add(key string, expires time.Time) (bool, error)
+}
+
+// A MemoryReplayStore is a ReplayStore that keeps keys in memory. When it is full, the least recently used keys are
+// forgotten first, even if they haven't expired, so its capacity should comfortably exceed the number of logins
+// expected within the Verifier's MaxAge. A key is used when it is added, and again each time it is rejected as a
+// replay, so a payload that is being replayed stays remembered.
+type MemoryReplayStore struct {
+	capacity int
+	now      func() time.Time
+
+	mu sync.Mutex
+	// entries holds *replayEntry values, with the most recently used at the front.
+	entries *list.List
+	keys    map[string]*list.Element
+}
+
+type replayEntry struct {
+	key     string
+	expires time.Time
+}
+
+// NewMemoryReplayStore returns a MemoryReplayStore that holds at most capacity keys. Keys are expired against now,
+// which should be the same clock as the Verifier's Now. If now is nil, time.Now is used.
+func NewMemoryReplayStore(capacity int, now func() time.Time) *MemoryReplayStore {
+	if capacity <= 0 {
+		panic("replay store capacity must be positive")
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &MemoryReplayStore{
+		capacity: capacity,
+		now:      now,
+		entries:  list.New(),
+		keys:     make(map[string]*list.Element),
+	}
+}
+
+// Add implements ReplayStore. It never returns an error.
+func (s *MemoryReplayStore) Add(key string, expires time.Time) (bool, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.keys[key]; ok {
+		if !expired(e.Value.(*replayEntry), now) {
+			s.entries.MoveToFront(e)
+			return false, nil
+		}
+		s.remove(e)
+	}
+
+	s.keys[key] = s.entries.PushFront(&replayEntry{key, expires})
+	for s.entries.Len() > s.capacity {
+		s.remove(s.entries.Back())
+	}
+	// Drop whatever has already expired from the old end so it doesn't take up space until evicted.
+	for e := s.entries.Back(); e != nil && expired(e.Value.(*replayEntry), now); e = s.entries.Back() {
+		s.remove(e)
+	}
+
+	return true, nil
+}
+
+// Len returns the number of keys currently held, including any that have expired but not yet been removed.
+func (s *MemoryReplayStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries.Len()
+}
+
+func (s *MemoryReplayStore) remove(e *list.Element) {
+	s.entries.Remove(e)
+	delete(s.keys, e.Value.(*replayEntry).key)
+}
+
+func expired(e *replayEntry, now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
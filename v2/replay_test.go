@@ -0,0 +1,124 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryReplayStore_RejectsDuplicate(t *testing.T) {
+	s := NewMemoryReplayStore(10, nil)
+	if added, _ := s.Add("a", time.Time{}); !added {
+		t.Error("first use should be added, but wasn't")
+	}
+	if added, _ := s.Add("a", time.Time{}); added {
+		t.Error("second use should be rejected, but was added")
+	}
+}
+
+func TestMemoryReplayStore_ForgetsExpiredKeys(t *testing.T) {
+	now := testAuthDate
+	s := NewMemoryReplayStore(10, func() time.Time { return now })
+	s.Add("a", now.Add(time.Minute))
+
+	now = now.Add(2 * time.Minute)
+	if added, _ := s.Add("a", now.Add(time.Minute)); !added {
+		t.Error("use after expiry should be added, but wasn't")
+	}
+}
+
+func TestMemoryReplayStore_EvictsOldestWhenFull(t *testing.T) {
+	s := NewMemoryReplayStore(2, nil)
+	s.Add("a", time.Time{})
+	s.Add("b", time.Time{})
+	s.Add("c", time.Time{})
+	if l := s.Len(); l != 2 {
+		t.Errorf("store should hold 2 keys, but held %d", l)
+	}
+	if added, _ := s.Add("a", time.Time{}); !added {
+		t.Error("evicted key should be added, but wasn't")
+	}
+	if added, _ := s.Add("c", time.Time{}); added {
+		t.Error("retained key should be rejected, but was added")
+	}
+}
+
+func TestMemoryReplayStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewMemoryReplayStore(2, nil)
+	s.Add("a", time.Time{})
+	s.Add("b", time.Time{})
+	s.Add("a", time.Time{})
+	s.Add("c", time.Time{})
+	if added, _ := s.Add("a", time.Time{}); added {
+		t.Error("recently replayed key should be rejected, but was added")
+	}
+	if added, _ := s.Add("b", time.Time{}); !added {
+		t.Error("least recently used key should be added, but wasn't")
+	}
+}
+
+func TestMemoryReplayStore_WithConcurrentUse(t *testing.T) {
+	s := NewMemoryReplayStore(100, nil)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	addedCount := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if added, _ := s.Add("a", time.Time{}); added {
+				mu.Lock()
+				addedCount++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if addedCount != 1 {
+		t.Errorf("key should be added exactly once, but was added %d times", addedCount)
+	}
+}
+
+func TestVerifier_WithReplayedData(t *testing.T) {
+	now := fixedClock(testAuthDate.Add(time.Minute))
+	v := Verifier{
+		TokenHash:   testBotTokenHash,
+		MaxAge:      time.Hour,
+		Now:         now,
+		ReplayStore: NewMemoryReplayStore(10, now),
+	}
+	if _, err := v.ConvertAndVerifyForm(testForm); err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if _, err := v.ConvertAndVerifyForm(testForm); err != ErrReplayed {
+		t.Errorf("expected ErrReplayed, but was %v", err)
+	}
+}
+
+func TestVerifier_DoesNotRecordInvalidData(t *testing.T) {
+	s := NewMemoryReplayStore(10, nil)
+	v := Verifier{
+		TokenHash:   testBotTokenHash,
+		MaxAge:      time.Hour,
+		Now:         fixedClock(testAuthDate.Add(2 * time.Hour)),
+		ReplayStore: s,
+	}
+	v.ConvertAndVerifyForm(testForm)
+	if l := s.Len(); l != 0 {
+		t.Errorf("expired data should not be recorded, but store held %d keys", l)
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+)
+
+// A Source identifies where in a request VerifyRequest found user data.
+type Source int
+
+const (
+	// SourceNone means that the request carried no user data.
+	SourceNone Source = iota
+	// SourceQuery means that the user data was in the query string, as sent by the data-auth-url redirect.
+	SourceQuery
+	// SourceForm means that the user data was in an application/x-www-form-urlencoded body.
+	SourceForm
+	// SourceJSON means that the user data was in an application/json body, as passed to the data-onauth callback.
+	SourceJSON
+	// SourceAuthResult means that the user data was in a tgAuthResult query or form parameter.
+	SourceAuthResult
+	// SourceWebApp means that the user data was Mini App initData in an "Authorization: tma" header.
+	SourceWebApp
+)
+
+func (s Source) String() string {
+	switch s {
+	case SourceNone:
+		return "none"
+	case SourceQuery:
+		return "query"
+	case SourceForm:
+		return "form"
+	case SourceJSON:
+		return "json"
+	case SourceAuthResult:
+		return "tgAuthResult"
+	case SourceWebApp:
+		return "webapp"
+	default:
+		return fmt.Sprintf("Source(%d)", int(s))
+	}
+}
+
+// VerifyRequest finds Telegram user data in r, whichever way it was sent, and verifies it with v, so that v's MaxAge,
+// ReplayStore, Limits and other settings apply whichever way the data came. It reports which Source the data came
+// from. If r carries no user data, it returns ErrNoCredentials.
+//
+// To choose the bot for each request, as LoginHandler does with its Resolver, pass the Verifier from ResolveVerifier. A
+// nil v is treated as an empty Verifier, which has no key for login widget data and so accepts only Mini App initData.
+//
+// Mini App initData is only accepted if webAppSecretKey, as returned from WebAppSecretKey, is not empty or v has a
+// TokenSource to derive the key from. Its auth date is checked with v.CheckAuthDate, but it isn't recorded in v's
+// ReplayStore, since a Mini App sends the same initData with every request. Request bodies larger than
+// DefaultMaxBodyBytes are rejected.
+func VerifyRequest(r *http.Request, v *Verifier, webAppSecretKey []byte) (User, Source, error) {
+	if v == nil {
+		v = &Verifier{}
+	}
+	if initData, ok := authorization(r, "tma"); ok && (len(webAppSecretKey) > 0 || v.TokenSource != nil) {
+		u, err := v.verifyWebApp(initData, webAppSecretKey)
+		return u, SourceWebApp, err
+	}
+
+	q := r.URL.Query()
+	if q.Has("tgAuthResult") {
+		u, err := v.ConvertAndVerifyAuthResult(q.Get("tgAuthResult"))
+		return u, SourceAuthResult, err
+	}
+
+	if r.Body != nil && r.Body != http.NoBody && (r.Method == http.MethodPost || r.Method == http.MethodPut) {
+		mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mt {
+		case "application/json":
+			u, err := v.ConvertAndVerifyJSON(http.MaxBytesReader(nil, r.Body, DefaultMaxBodyBytes))
+			return u, SourceJSON, err
+		case "application/x-www-form-urlencoded":
+			r.Body = http.MaxBytesReader(nil, r.Body, DefaultMaxBodyBytes)
+			if err := r.ParseForm(); err != nil {
+				return User{}, SourceForm, fmt.Errorf("%w: %v", ErrMalformed, err)
+			}
+			if r.PostForm.Has("tgAuthResult") {
+				u, err := v.ConvertAndVerifyAuthResult(r.PostForm.Get("tgAuthResult"))
+				return u, SourceAuthResult, err
+			}
+			if r.PostForm.Has("hash") {
+				u, err := v.ConvertAndVerifyForm(r.PostForm)
+				return u, SourceForm, err
+			}
+		}
+	}
+
+	if q.Has("hash") {
+		u, err := v.ConvertAndVerifyForm(q)
+		return u, SourceQuery, err
+	}
+
+	return User{}, SourceNone, ErrNoCredentials
+}
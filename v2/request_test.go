@@ -0,0 +1,128 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyRequest_DetectsSource(t *testing.T) {
+	webAppKey := WebAppSecretKey(testBotToken)
+
+	query := httptest.NewRequest(http.MethodGet, "/login?"+testForm.Encode(), nil)
+
+	form := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(testForm.Encode()))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	json := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(testUserJSON))
+	json.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	authResult := httptest.NewRequest(http.MethodGet, "/login?tgAuthResult="+testAuthResult, nil)
+
+	webApp := httptest.NewRequest(http.MethodGet, "/api", nil)
+	webApp.Header.Set("Authorization", "tma "+testInitData)
+
+	for _, c := range []struct {
+		r    *http.Request
+		want Source
+	}{
+		{query, SourceQuery},
+		{form, SourceForm},
+		{json, SourceJSON},
+		{authResult, SourceAuthResult},
+		{webApp, SourceWebApp},
+	} {
+		u, s, err := VerifyRequest(c.r, testVerifier(), webAppKey)
+		if err != nil {
+			t.Errorf("failed to verify %v request: %v", c.want, err)
+			continue
+		}
+		if s != c.want {
+			t.Errorf("source should be %v, but was %v", c.want, s)
+		}
+		if u.ID != 12345678 {
+			t.Errorf("ID from %v should be 12345678, but was %d", c.want, u.ID)
+		}
+	}
+}
+
+func TestVerifyRequest_WithoutUserData(t *testing.T) {
+	_, s, err := VerifyRequest(httptest.NewRequest(http.MethodGet, "/login?next=/", nil), testVerifier(), nil)
+	if err != ErrNoCredentials || s != SourceNone {
+		t.Errorf("expected ErrNoCredentials from none, but was %v from %v", err, s)
+	}
+}
+
+func TestVerifyRequest_WithWebAppButNoKey(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.Header.Set("Authorization", "tma "+testInitData)
+	if _, _, err := VerifyRequest(r, testVerifier(), nil); err != ErrNoCredentials {
+		t.Errorf("expected ErrNoCredentials, but was %v", err)
+	}
+}
+
+func TestVerifyRequest_WithIncorrectHash(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/login?"+withParam(testForm, "username", "mallory").Encode(), nil)
+	if _, s, err := VerifyRequest(r, testVerifier(), nil); err != ErrInvalidHash || s != SourceQuery {
+		t.Errorf("expected ErrInvalidHash from query, but was %v from %v", err, s)
+	}
+}
+
+func TestVerifyRequest_AppliesVerifier(t *testing.T) {
+	v := testVerifier()
+	v.Now = fixedClock(testAuthDate.Add(2 * time.Hour))
+
+	query := httptest.NewRequest(http.MethodGet, "/login?"+testForm.Encode(), nil)
+	if _, _, err := VerifyRequest(query, v, nil); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired from query, but was %v", err)
+	}
+
+	webApp := httptest.NewRequest(http.MethodGet, "/api", nil)
+	webApp.Header.Set("Authorization", "tma "+testInitData)
+	if _, _, err := VerifyRequest(webApp, v, WebAppSecretKey(testBotToken)); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired from initData, but was %v", err)
+	}
+}
+
+func TestVerifyRequest_WithReplayedData(t *testing.T) {
+	v := testVerifier()
+	v.ReplayStore = NewMemoryReplayStore(10, v.Now)
+	authResult := "/login?tgAuthResult=" + testAuthResult
+	if _, _, err := VerifyRequest(httptest.NewRequest(http.MethodGet, authResult, nil), v, nil); err != nil {
+		t.Fatalf("failed to verify: %v", err)
+	}
+	query := httptest.NewRequest(http.MethodGet, "/login?"+testForm.Encode(), nil)
+	if _, _, err := VerifyRequest(query, v, nil); err != ErrReplayed {
+		t.Errorf("expected ErrReplayed, but was %v", err)
+	}
+}
+
+func TestVerifyRequest_WithNilVerifier(t *testing.T) {
+	webApp := httptest.NewRequest(http.MethodGet, "/api", nil)
+	webApp.Header.Set("Authorization", "tma "+testInitData)
+	if _, _, err := VerifyRequest(webApp, nil, WebAppSecretKey(testBotToken)); err != nil {
+		t.Errorf("failed to verify initData: %v", err)
+	}
+
+	query := httptest.NewRequest(http.MethodGet, "/login?"+testForm.Encode(), nil)
+	if _, _, err := VerifyRequest(query, nil, nil); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
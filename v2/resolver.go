@@ -0,0 +1,173 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownBot indicates that no bot is configured for the site or tenant that a request was made to.
+var ErrUnknownBot = errors.New("no bot is configured for the request")
+
+// A BotResolver chooses the hashed bot token, as returned from HashBotToken, to verify the data in r with. It lets one
+// handler serve several sites, each with its own bot, since Telegram binds each bot's login widget to a single domain.
+// It should return ErrUnknownBot if there is no bot for r. An empty token hash is also treated as ErrUnknownBot, since
+// it would verify data against a key that anyone can compute.
+type BotResolver func(r *http.Request) ([]byte, error)
+
+// resolveBot returns the token hash and Verifier that the data in r should be verified with. If resolver is nil, they
+// are tokenHash and v. Otherwise the resolved token hash replaces tokenHash and, in a copy of v, v's TokenHash, MAC,
+// TokenSource and KeyRing.
+func resolveBot(r *http.Request, resolver BotResolver, tokenHash []byte, v *Verifier) ([]byte, *Verifier, error) {
+	if resolver == nil {
+		return tokenHash, v, nil
+	}
+	tokenHash, err := resolver(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokenHash) == 0 {
+		return nil, nil, ErrUnknownBot
+	}
+	if v != nil {
+		resolved := *v
+		resolved.TokenHash = tokenHash
+		resolved.MAC = nil
+		resolved.TokenSource = nil
+		resolved.KeyRing = nil
+		v = &resolved
+	}
+	return tokenHash, v, nil
+}
+
+// ResolveVerifier returns a copy of v that verifies data with the token hash that resolver chooses for r, for use with
+// VerifyRequest. The copy keeps v's other settings, but not its MAC, TokenSource or KeyRing. If v is nil, the copy has
+// no other settings, and if resolver is nil, v itself is returned.
+//
+// There is no equivalent for WebAppAuthenticator, because a BotResolver returns the hash of a bot token, from which the
+// Mini App key can't be derived. Sites with a Mini App per bot need an Authenticator for each.
+func ResolveVerifier(r *http.Request, resolver BotResolver, v *Verifier) (*Verifier, error) {
+	if v == nil {
+		v = &Verifier{}
+	}
+	_, v, err := resolveBot(r, resolver, nil, v)
+	return v, err
+}
+
+// RequestHost returns the host that r was made to, in lower case and without a port. It is the default tenant of a
+// CachedResolver.
+func RequestHost(r *http.Request) (string, error) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "" {
+		return "", ErrUnknownBot
+	}
+	return host, nil
+}
+
+// A CachedResolver looks up the bot for each tenant, such as a customer's domain, and remembers it. Its Resolve method
+// is a BotResolver.
+//
+// Only successful lookups are remembered, so that requests for unknown tenants can't fill the cache. Lookup is still
+// called for each of them.
+//
+// A CachedResolver must not be copied or modified after first use, but may then be used concurrently.
+type CachedResolver struct {
+	// Tenant returns the tenant that r was made to. If Tenant is nil, RequestHost is used. Behind a proxy that
+	// rewrites the Host header, Tenant should read the original host from wherever the proxy puts it.
+	Tenant func(r *http.Request) (string, error)
+
+	// Lookup returns the hashed bot token for tenant, or ErrUnknownBot if there is none. An empty token hash is treated
+	// as ErrUnknownBot.
+	Lookup func(ctx context.Context, tenant string) ([]byte, error)
+
+	// TTL is how long a looked up token hash is remembered. If TTL is zero, it is remembered until Forget is called.
+	TTL time.Duration
+
+	// Now returns the current time. If Now is nil, time.Now is used.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]resolvedBot
+}
+
+type resolvedBot struct {
+	tokenHash []byte
+	expires   time.Time
+}
+
+// Resolve returns the hashed bot token for the tenant of r, looking it up if it isn't remembered.
+func (c *CachedResolver) Resolve(r *http.Request) ([]byte, error) {
+	tenantOf := c.Tenant
+	if tenantOf == nil {
+		tenantOf = RequestHost
+	}
+	tenant, err := tenantOf(r)
+	if err != nil {
+		return nil, err
+	}
+
+	now := c.now()
+	c.mu.Lock()
+	e, ok := c.entries[tenant]
+	c.mu.Unlock()
+	if ok && (e.expires.IsZero() || now.Before(e.expires)) {
+		return e.tokenHash, nil
+	}
+
+	// The lock isn't held during the lookup, so concurrent requests for the same tenant may each look it up.
+	tokenHash, err := c.Lookup(r.Context(), tenant)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokenHash) == 0 {
+		return nil, ErrUnknownBot
+	}
+	e = resolvedBot{tokenHash: tokenHash}
+	if c.TTL > 0 {
+		e.expires = now.Add(c.TTL)
+	}
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]resolvedBot)
+	}
+	c.entries[tenant] = e
+	c.mu.Unlock()
+	return tokenHash, nil
+}
+
+// Forget removes what is remembered about tenant, so that it is looked up again on the next request. It should be
+// called when a tenant's bot token changes.
+func (c *CachedResolver) Forget(tenant string) {
+	c.mu.Lock()
+	delete(c.entries, tenant)
+	c.mu.Unlock()
+}
+
+func (c *CachedResolver) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
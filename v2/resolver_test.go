@@ -0,0 +1,180 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testTenants returns a CachedResolver for two hosts, and a pointer to the number of lookups it has made.
+func testTenants(now func() time.Time) (*CachedResolver, *int) {
+	lookups := 0
+	hashes := map[string][]byte{
+		"a.example.com": testBotTokenHash,
+		"b.example.com": otherBotTokenHash,
+	}
+	return &CachedResolver{
+		Lookup: func(ctx context.Context, tenant string) ([]byte, error) {
+			lookups++
+			if h, ok := hashes[tenant]; ok {
+				return h, nil
+			}
+			return nil, ErrUnknownBot
+		},
+		TTL: time.Minute,
+		Now: now,
+	}, &lookups
+}
+
+func TestRequestHost(t *testing.T) {
+	for host, want := range map[string]string{
+		"a.example.com":      "a.example.com",
+		"A.Example.COM:8443": "a.example.com",
+		"a.example.com.":     "a.example.com",
+		"[::1]:80":           "::1",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Host = host
+		if got, err := RequestHost(r); err != nil || got != want {
+			t.Errorf("host of %q should be %q, but was %q, %v", host, want, got, err)
+		}
+	}
+}
+
+func TestCachedResolver_RemembersLookups(t *testing.T) {
+	now := time.Unix(1512345678, 0)
+	c, lookups := testTenants(func() time.Time { return now })
+	r := httptest.NewRequest(http.MethodGet, "https://a.example.com/login", nil)
+
+	for i := 0; i < 2; i++ {
+		if _, err := c.Resolve(r); err != nil {
+			t.Fatalf("failed to resolve: %v", err)
+		}
+	}
+	if *lookups != 1 {
+		t.Errorf("should have looked up once, but looked up %d times", *lookups)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := c.Resolve(r); err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+	if *lookups != 2 {
+		t.Errorf("should have looked up again after TTL, but looked up %d times", *lookups)
+	}
+
+	c.Forget("a.example.com")
+	if _, err := c.Resolve(r); err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+	if *lookups != 3 {
+		t.Errorf("should have looked up again after Forget, but looked up %d times", *lookups)
+	}
+}
+
+func TestCachedResolver_DoesNotRememberUnknownTenants(t *testing.T) {
+	c, _ := testTenants(nil)
+	if _, err := c.Resolve(httptest.NewRequest(http.MethodGet, "https://evil.example.com/login", nil)); err != ErrUnknownBot {
+		t.Errorf("expected ErrUnknownBot, but was %v", err)
+	}
+	if len(c.entries) != 0 {
+		t.Errorf("unknown tenant should not be remembered, but %d entries were", len(c.entries))
+	}
+}
+
+func TestCachedResolver_DoesNotRememberEmptyHashes(t *testing.T) {
+	c := &CachedResolver{Lookup: func(ctx context.Context, tenant string) ([]byte, error) { return nil, nil }}
+	if _, err := c.Resolve(httptest.NewRequest(http.MethodGet, "https://a.example.com/login", nil)); err != ErrUnknownBot {
+		t.Errorf("expected ErrUnknownBot, but was %v", err)
+	}
+	if len(c.entries) != 0 {
+		t.Errorf("empty hash should not be remembered, but %d entries were", len(c.entries))
+	}
+}
+
+func TestLoginHandler_WithResolver(t *testing.T) {
+	c, _ := testTenants(nil)
+	h := NewLoginHandler(nil, nil)
+	h.Resolver = c.Resolve
+	h.Verifier = testVerifier()
+
+	for host, want := range map[string]int{
+		"a.example.com":    http.StatusSeeOther,
+		"b.example.com":    http.StatusUnauthorized,
+		"evil.example.com": http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://"+host+"/login?"+testForm.Encode(), nil))
+		if w.Code != want {
+			t.Errorf("status for %s should be %d, but was %d", host, want, w.Code)
+		}
+	}
+}
+
+func TestLoginHandler_WithResolverAndVerifier(t *testing.T) {
+	c, _ := testTenants(nil)
+	h := NewLoginHandler(nil, nil)
+	h.Resolver = c.Resolve
+	h.Verifier = &Verifier{
+		TokenHash: otherBotTokenHash,
+		MaxAge:    time.Hour,
+		Now:       fixedClock(testAuthDate.Add(2 * time.Hour)),
+	}
+
+	// The resolved token hash is used, but the Verifier still rejects the data for its age.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://a.example.com/login?"+testForm.Encode(), nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status should be 401, but was %d", w.Code)
+	}
+
+	h.Verifier.MaxAge = 0
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "https://a.example.com/login?"+testForm.Encode(), nil))
+	if w.Code != http.StatusSeeOther {
+		t.Errorf("status should be 303, but was %d", w.Code)
+	}
+}
+
+func TestLoginHandler_WithResolverReturningEmptyHash(t *testing.T) {
+	h := NewLoginHandler(nil, nil)
+	h.Resolver = func(r *http.Request) ([]byte, error) { return nil, nil }
+	w := serveLogin(h, testForm)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status should be 404, but was %d", w.Code)
+	}
+}
+
+func TestVerifyRequest_WithResolvedVerifier(t *testing.T) {
+	c, _ := testTenants(nil)
+	for host, want := range map[string]error{
+		"a.example.com":    nil,
+		"b.example.com":    ErrInvalidHash,
+		"evil.example.com": ErrUnknownBot,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "https://"+host+"/login?"+testForm.Encode(), nil)
+		v, err := ResolveVerifier(r, c.Resolve, &Verifier{TokenHash: otherBotTokenHash})
+		if err == nil {
+			_, _, err = VerifyRequest(r, v, nil)
+		}
+		if err != want {
+			t.Errorf("error for %s should be %v, but was %v", host, want, err)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrInvalidSession indicates that a session cookie could not be authenticated with any of the session keys.
+var ErrInvalidSession = errors.New("the session is invalid")
+
+// ErrSessionExpired indicates that a session cookie was authenticated, but has expired.
+var ErrSessionExpired = errors.New("the session has expired")
+
+// DefaultSessionCookieName is the name of the session cookie if Sessions.CookieName is empty.
+const DefaultSessionCookieName = "telegram_session"
+
+// DefaultSessionMaxAge is how long sessions last if Sessions.MaxAge is zero.
+const DefaultSessionMaxAge = 24 * time.Hour
+
+// A SessionKey is a secret used to protect session cookies. The ID is stored in each cookie so that the right key can
+// be found when keys are rotated, and must not contain a period. The secret must be at least 32 random bytes.
+type SessionKey struct {
+	ID     string
+	Secret []byte
+}
+
+// Sessions stores a User in a cookie once they have logged in, so that later requests can be authenticated without
+// sending the login widget's data again. Cookies are authenticated with HMAC-SHA256 and can optionally be encrypted
+// with AES-GCM so that the user's details aren't visible to the browser.
+//
+// Its OnLogin method can be used as LoginHandler.OnLogin, and its Authenticator method provides an Authenticator for
+// Middleware.
+//
+// A Sessions must not be modified after first use, but may then be used concurrently.
+type Sessions struct {
+	// Keys is the key ring. New cookies are protected with the first key, and cookies protected with any of the keys
+	// are accepted. To rotate keys, add a new key to the front, and remove the old one once all the cookies it
+	// protects have expired.
+	Keys []SessionKey
+
+	// Encrypt causes cookies to be encrypted as well as authenticated. Cookies written with one setting can't be read
+	// with the other.
+	Encrypt bool
+
+	// MaxAge is how long a session lasts. If MaxAge is zero, DefaultSessionMaxAge is used.
+	MaxAge time.Duration
+
+	// CookieName is the name of the session cookie. If CookieName is empty, DefaultSessionCookieName is used.
+	CookieName string
+
+	// Path and Domain are set on the session cookie. If Path is empty, "/" is used.
+	Path   string
+	Domain string
+
+	// Insecure allows the session cookie to be sent over plain HTTP. It should only be set in development.
+	Insecure bool
+
+	// SameSite is set on the session cookie. If SameSite is zero, http.SameSiteLaxMode is used, which still allows
+	// the cookie to be sent when following a link into the site.
+	SameSite http.SameSite
+
+	// Now returns the current time. If Now is nil, time.Now is used.
+	Now func() time.Time
+}
+
+// session is the serialized form of a User in a session cookie.
+type session struct {
+	ID        int64             `json:"id"`
+	FirstName string            `json:"first_name,omitempty"`
+	LastName  string            `json:"last_name,omitempty"`
+	Username  string            `json:"username,omitempty"`
+	PhotoURL  string            `json:"photo_url,omitempty"`
+	AuthDate  int64             `json:"auth_date"`
+	Extra     map[string]string `json:"extra,omitempty"`
+	Fields    Fields            `json:"fields,omitempty"`
+	Expires   int64             `json:"exp"`
+}
+
+// Encode returns the value of a session cookie for u.
+func (s *Sessions) Encode(u User) (string, error) {
+	if err := s.checkKeys(); err != nil {
+		return "", err
+	}
+	k := s.Keys[0]
+
+	ss := session{
+		ID:        u.ID,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Username:  u.Username,
+		AuthDate:  u.AuthDate.Unix(),
+		Extra:     u.Extra,
+		Fields:    u.Fields,
+		Expires:   s.now().Add(s.maxAge()).Unix(),
+	}
+	if u.PhotoURL != nil {
+		ss.PhotoURL = u.PhotoURL.String()
+	}
+	payload, err := json.Marshal(ss)
+	if err != nil {
+		return "", err
+	}
+
+	var mode string
+	var body []byte
+	if s.Encrypt {
+		mode = "e"
+		aead, err := newSessionAEAD(k.Secret)
+		if err != nil {
+			return "", err
+		}
+		nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(payload)+aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		body = aead.Seal(nonce, nonce, payload, s.additionalData(mode, k.ID))
+	} else {
+		mode = "s"
+		body = append(payload, s.sessionMAC(k.Secret, mode, k.ID, payload)...)
+	}
+
+	return mode + "." + k.ID + "." + base64.RawURLEncoding.EncodeToString(body), nil
+}
+
+// Decode returns the User in a session cookie value produced by Encode.
+func (s *Sessions) Decode(v string) (User, error) {
+	// The cookie chooses the key, so a weak retired key would be as good as a weak current one to a forger.
+	if err := s.checkKeys(); err != nil {
+		return User{}, err
+	}
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) != 3 {
+		return User{}, ErrInvalidSession
+	}
+	mode, id := parts[0], parts[1]
+	body, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return User{}, ErrInvalidSession
+	}
+	k, ok := s.key(id)
+	if !ok {
+		return User{}, ErrInvalidSession
+	}
+
+	var payload []byte
+	switch {
+	case mode == "e" && s.Encrypt:
+		aead, err := newSessionAEAD(k.Secret)
+		if err != nil {
+			return User{}, err
+		}
+		if len(body) < aead.NonceSize() {
+			return User{}, ErrInvalidSession
+		}
+		nonce, sealed := body[:aead.NonceSize()], body[aead.NonceSize():]
+		if payload, err = aead.Open(nil, nonce, sealed, s.additionalData(mode, k.ID)); err != nil {
+			return User{}, ErrInvalidSession
+		}
+	case mode == "s" && !s.Encrypt:
+		if len(body) < sha256.Size {
+			return User{}, ErrInvalidSession
+		}
+		payload = body[:len(body)-sha256.Size]
+		if !hmac.Equal(body[len(body)-sha256.Size:], s.sessionMAC(k.Secret, mode, k.ID, payload)) {
+			return User{}, ErrInvalidSession
+		}
+	default:
+		return User{}, ErrInvalidSession
+	}
+
+	var ss session
+	if err := json.Unmarshal(payload, &ss); err != nil {
+		return User{}, ErrInvalidSession
+	}
+	if !s.now().Before(time.Unix(ss.Expires, 0)) {
+		return User{}, ErrSessionExpired
+	}
+
+	u := User{
+		AuthDate:  time.Unix(ss.AuthDate, 0),
+		FirstName: ss.FirstName,
+		ID:        ss.ID,
+		LastName:  ss.LastName,
+		Username:  ss.Username,
+		Extra:     ss.Extra,
+		Fields:    ss.Fields,
+	}
+	if ss.PhotoURL != "" {
+		if u.PhotoURL, err = url.Parse(ss.PhotoURL); err != nil {
+			return User{}, ErrInvalidSession
+		}
+	}
+	return u, nil
+}
+
+// SetCookie sets a session cookie for u on w.
+func (s *Sessions) SetCookie(w http.ResponseWriter, u User) error {
+	v, err := s.Encode(u)
+	if err != nil {
+		return err
+	}
+	c := s.cookie()
+	c.Value = v
+	c.MaxAge = int(s.maxAge() / time.Second)
+	http.SetCookie(w, c)
+	return nil
+}
+
+// ClearCookie removes the session cookie from the browser, logging the user out.
+func (s *Sessions) ClearCookie(w http.ResponseWriter) {
+	c := s.cookie()
+	c.MaxAge = -1
+	http.SetCookie(w, c)
+}
+
+// OnLogin sets a session cookie for u. It has the signature of LoginHandler.OnLogin.
+func (s *Sessions) OnLogin(w http.ResponseWriter, r *http.Request, u User) error {
+	return s.SetCookie(w, u)
+}
+
+// Authenticator returns an Authenticator that reads the session cookie.
+func (s *Sessions) Authenticator() Authenticator {
+	return CookieAuthenticator(s.cookieName(), s.Decode)
+}
+
+func (s *Sessions) cookie() *http.Cookie {
+	path := s.Path
+	if path == "" {
+		path = "/"
+	}
+	sameSite := s.SameSite
+	if sameSite == 0 {
+		sameSite = http.SameSiteLaxMode
+	}
+	return &http.Cookie{
+		Name:     s.cookieName(),
+		Path:     path,
+		Domain:   s.Domain,
+		Secure:   !s.Insecure,
+		HttpOnly: true,
+		SameSite: sameSite,
+	}
+}
+
+func (s *Sessions) key(id string) (SessionKey, bool) {
+	for _, k := range s.Keys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return SessionKey{}, false
+}
+
+// additionalData binds a cookie to its mode, key and name, so that it can't be reinterpreted as another.
+func (s *Sessions) additionalData(mode, keyID string) []byte {
+	return []byte(mode + "." + keyID + "." + s.cookieName())
+}
+
+func (s *Sessions) sessionMAC(secret []byte, mode, keyID string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, deriveSessionKey(secret, "signing"))
+	mac.Write(s.additionalData(mode, keyID))
+	mac.Write([]byte{0})
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+func (s *Sessions) cookieName() string {
+	if s.CookieName == "" {
+		return DefaultSessionCookieName
+	}
+	return s.CookieName
+}
+
+func (s *Sessions) maxAge() time.Duration {
+	if s.MaxAge == 0 {
+		return DefaultSessionMaxAge
+	}
+	return s.MaxAge
+}
+
+func (s *Sessions) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+// checkKeys returns an error if s has no keys, or if any of them is unsafe to use.
+func (s *Sessions) checkKeys() error {
+	if len(s.Keys) == 0 {
+		return errors.New("no session keys")
+	}
+	for _, k := range s.Keys {
+		if err := checkSessionKey(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkSessionKey(k SessionKey) error {
+	if strings.Contains(k.ID, ".") {
+		return fmt.Errorf("session key ID %q contains a period", k.ID)
+	}
+	if len(k.Secret) < 32 {
+		return fmt.Errorf("session key %q is shorter than 32 bytes", k.ID)
+	}
+	return nil
+}
+
+// deriveSessionKey derives independent keys for each use of a session secret.
+func deriveSessionKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("telegramwidget session " + purpose))
+	return mac.Sum(nil)
+}
+
+func newSessionAEAD(secret []byte) (cipher.AEAD, error) {
+	b, err := aes.NewCipher(deriveSessionKey(secret, "encryption"))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(b)
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testSessionKeyA = SessionKey{ID: "a", Secret: bytes.Repeat([]byte{'a'}, 32)}
+	testSessionKeyB = SessionKey{ID: "b", Secret: bytes.Repeat([]byte{'b'}, 32)}
+)
+
+var testUser = User{
+	AuthDate:  testAuthDate,
+	FirstName: "John 🕶",
+	ID:        12345678,
+	LastName:  "Smith",
+	PhotoURL:  &url.URL{Scheme: "https", Host: "t.me", Path: "/i/userpic/320/jsmith.jpg"},
+	Username:  "jsmith",
+	Fields:    FieldFirstName | FieldLastName | FieldPhotoURL | FieldUsername,
+}
+
+func TestSessions_RoundTrip(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		s := Sessions{Keys: []SessionKey{testSessionKeyA}, Encrypt: encrypt}
+		v, err := s.Encode(testUser)
+		if err != nil {
+			t.Fatalf("failed to encode: %v", err)
+		}
+		if encrypt && strings.Contains(v, "jsmith") {
+			t.Errorf("encrypted session should not reveal username, but was %v", v)
+		}
+		u, err := s.Decode(v)
+		if err != nil {
+			t.Fatalf("failed to decode: %v", err)
+		}
+		if u.ID != testUser.ID || u.Username != testUser.Username || u.PhotoURL.String() != testUser.PhotoURL.String() {
+			t.Errorf("decoded user should be %v, but was %v", testUser, u)
+		}
+		if !u.AuthDate.Equal(testAuthDate) {
+			t.Errorf("auth date should be %v, but was %v", testAuthDate, u.AuthDate)
+		}
+		if u.Fields != testUser.Fields {
+			t.Errorf("fields should be %b, but were %b", testUser.Fields, u.Fields)
+		}
+	}
+}
+
+func TestSessions_WithTamperedCookie(t *testing.T) {
+	for _, encrypt := range []bool{false, true} {
+		s := Sessions{Keys: []SessionKey{testSessionKeyA}, Encrypt: encrypt}
+		v, _ := s.Encode(testUser)
+		b := []byte(v)
+		b[len(b)-5] ^= 1
+		if _, err := s.Decode(string(b)); err != ErrInvalidSession {
+			t.Errorf("expected ErrInvalidSession, but was %v", err)
+		}
+	}
+}
+
+func TestSessions_WithRotatedKeys(t *testing.T) {
+	old := Sessions{Keys: []SessionKey{testSessionKeyA}}
+	v, _ := old.Encode(testUser)
+
+	rotated := Sessions{Keys: []SessionKey{testSessionKeyB, testSessionKeyA}}
+	if _, err := rotated.Decode(v); err != nil {
+		t.Errorf("cookie from old key should be accepted during rotation, but was %v", err)
+	}
+
+	retired := Sessions{Keys: []SessionKey{testSessionKeyB}}
+	if _, err := retired.Decode(v); err != ErrInvalidSession {
+		t.Errorf("expected ErrInvalidSession after key removal, but was %v", err)
+	}
+}
+
+func TestSessions_WithExpiredCookie(t *testing.T) {
+	now := testAuthDate
+	s := Sessions{Keys: []SessionKey{testSessionKeyA}, MaxAge: time.Hour, Now: func() time.Time { return now }}
+	v, _ := s.Encode(testUser)
+	now = now.Add(2 * time.Hour)
+	if _, err := s.Decode(v); err != ErrSessionExpired {
+		t.Errorf("expected ErrSessionExpired, but was %v", err)
+	}
+}
+
+func TestSessions_WithShortKey(t *testing.T) {
+	s := Sessions{Keys: []SessionKey{{ID: "short", Secret: []byte("secret")}}}
+	if _, err := s.Encode(testUser); err == nil {
+		t.Error("should have returned error, but was nil")
+	}
+}
+
+func TestSessions_WithShortRetiredKey(t *testing.T) {
+	s := Sessions{Keys: []SessionKey{testSessionKeyA, {ID: "old"}}, Now: fixedClock(testAuthDate)}
+	payload := []byte(`{"id":1,"auth_date":1512345678,"exp":1612345678}`)
+	body := append(payload, s.sessionMAC(nil, "s", "old", payload)...)
+	forged := "s.old." + base64.RawURLEncoding.EncodeToString(body)
+	if _, err := s.Decode(forged); err == nil {
+		t.Error("should have returned error, but was nil")
+	}
+}
+
+func TestSessions_SetCookieDefaults(t *testing.T) {
+	s := Sessions{Keys: []SessionKey{testSessionKeyA}}
+	w := httptest.NewRecorder()
+	if err := s.SetCookie(w, testUser); err != nil {
+		t.Fatalf("failed to set cookie: %v", err)
+	}
+	cs := w.Result().Cookies()
+	if len(cs) != 1 {
+		t.Fatalf("should set one cookie, but set %d", len(cs))
+	}
+	c := cs[0]
+	if c.Name != DefaultSessionCookieName || !c.Secure || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode || c.Path != "/" {
+		t.Errorf("cookie should have secure defaults, but was %v", c)
+	}
+}
+
+func TestSessions_WithLoginHandlerAndMiddleware(t *testing.T) {
+	s := &Sessions{Keys: []SessionKey{testSessionKeyA}}
+	w := serveLogin(newTestLoginHandler(s.OnLogin), testForm)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+	var u User
+	var ok bool
+	m := Middleware{Authenticators: []Authenticator{s.Authenticator()}}
+	m.Handler(recordUser(&u, &ok)).ServeHTTP(httptest.NewRecorder(), r)
+	if !ok || u.ID != 12345678 {
+		t.Errorf("user 12345678 should be in context, but was %v, %v", u, ok)
+	}
+}
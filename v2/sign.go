@@ -0,0 +1,61 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+)
+
+// SignForm returns a copy of f with its hash field set to the hash Telegram would compute for the other fields with
+// key. The key is a hashed bot token from HashBotToken for login widget data, or a key from WebAppSecretKey for Mini
+// App initData. Each field must have exactly one value.
+//
+// SignForm is the inverse of verification, and is mostly useful for producing test data. The telegramwidgettest
+// package builds on it to produce data in each of the formats that Telegram sends.
+func SignForm(f url.Values, key []byte) (url.Values, error) {
+	s, err := DataCheckString(f)
+	if err != nil {
+		return nil, err
+	}
+	signed := make(url.Values, len(f)+1)
+	for k := range f {
+		signed.Set(k, f.Get(k))
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(s))
+	signed.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+	return signed, nil
+}
+
+// DataCheckString returns the string that Telegram computes the hash over for the fields in f: every field other than
+// hash, sorted by name, as "name=value" lines. Each field must have exactly one value. It is mostly useful for
+// explaining why a hash doesn't match.
+func DataCheckString(f url.Values) (string, error) {
+	ps := make([]pair, 0, len(f))
+	for k, vs := range f {
+		if k == "hash" {
+			continue
+		}
+		if len(vs) != 1 {
+			return "", newFieldError(k, "", ErrNotSingleValue, nil)
+		}
+		ps = append(ps, pair{k, vs[0]})
+	}
+	return constructCheckString(ps), nil
+}
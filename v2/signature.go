@@ -0,0 +1,114 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// These are the hex-encoded Ed25519 public keys Telegram uses to sign Mini App initData for third parties.
+const (
+	productionPublicKeyHex = "e7bf03a2fa4602af4580703d88dda5bb59f32ed8b02a56c187fe7d34caed242d"
+	testPublicKeyHex       = "40055058a4ee38156a06562e52eece92a771bcd8346a8c4615cb7376eddf72ec"
+)
+
+var (
+	productionPublicKey = mustDecodePublicKey(productionPublicKeyHex)
+	testPublicKey       = mustDecodePublicKey(testPublicKeyHex)
+)
+
+// ProductionPublicKey returns the key Telegram signs Mini App initData with in the production environment. Each call
+// returns a new copy, so that no caller can change the key for the rest of the process.
+func ProductionPublicKey() ed25519.PublicKey {
+	return append(ed25519.PublicKey(nil), productionPublicKey...)
+}
+
+// TestPublicKey returns the key Telegram signs Mini App initData with in the test environment. Each call returns a new
+// copy.
+func TestPublicKey() ed25519.PublicKey {
+	return append(ed25519.PublicKey(nil), testPublicKey...)
+}
+
+// ErrInvalidSignature indicates that the data received could not be authenticated with its signature property and the
+// provided public key.
+var ErrInvalidSignature = errors.New("the signature is invalid")
+
+// ConvertAndVerifyWebAppInitDataSignature accepts the raw query string from Telegram.WebApp.initData and parses it into
+// the returned WebAppInitData. Unlike ConvertAndVerifyWebAppInitData, the data is validated using its signature
+// property, so only the ID of the bot that the Mini App belongs to is needed rather than its token. The public key is
+// usually from ProductionPublicKey or TestPublicKey.
+func ConvertAndVerifyWebAppInitDataSignature(initData string, botID int64, publicKey ed25519.PublicKey) (WebAppInitData, error) {
+	// ed25519.Verify panics on a key of the wrong size.
+	if len(publicKey) != ed25519.PublicKeySize {
+		return WebAppInitData{}, fmt.Errorf("public key is %d bytes, but must be %d", len(publicKey), ed25519.PublicKeySize)
+	}
+	if err := DefaultLimits.checkBytes(int64(len(initData))); err != nil {
+		return WebAppInitData{}, err
+	}
+	f, err := url.ParseQuery(initData)
+	if err != nil {
+		return WebAppInitData{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	d, ps, _, err := parseWebAppInitData(f, DefaultLimits)
+	if err != nil {
+		return d, err
+	}
+
+	// parseWebAppInitData has already rejected repeated keys.
+	encoded := f.Get("signature")
+	if encoded == "" {
+		return d, ErrInvalidSignature
+	}
+	// Telegram sends unpadded URL-safe base64, but be lenient about padding.
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return d, newFieldError("signature", encoded, ErrMalformed, err)
+	}
+
+	// The signature itself is not part of the signed data.
+	ps = withoutKey(ps, "signature")
+	if !ed25519.Verify(publicKey, []byte(constructSignatureCheckString(botID, ps)), sig) {
+		return d, ErrInvalidSignature
+	}
+
+	return d, nil
+}
+
+// constructSignatureCheckString accepts key-value pairs of Mini App initData and constructs the string that Telegram
+// signs for third-party validation. This is the same as the string from constructCheckString, prefixed with a line
+// identifying the bot.
+func constructSignatureCheckString(botID int64, ps []pair) string {
+	prefix := strconv.FormatInt(botID, 10) + ":WAWebAppData"
+	if len(ps) == 0 {
+		return prefix
+	}
+	return prefix + "\n" + constructCheckString(ps)
+}
+
+func mustDecodePublicKey(s string) ed25519.PublicKey {
+	k, err := hex.DecodeString(s)
+	if err != nil || len(k) != ed25519.PublicKeySize {
+		panic(fmt.Sprintf("malformed hardcoded public key %q", s))
+	}
+	return ed25519.PublicKey(k)
+}
@@ -0,0 +1,102 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"net/url"
+	"testing"
+)
+
+// fakeTelegramPublicKey corresponds to the all-zeroes Ed25519 seed, which stands in for Telegram's private key.
+var fakeTelegramPublicKey = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+
+const testBotID = 123456789
+
+func TestConvertAndVerifyWebAppInitDataSignature_WithValidSignature(t *testing.T) {
+	d, err := ConvertAndVerifyWebAppInitDataSignature(url.Values{
+		"auth_date": {"1512345678"},
+		"hash":      {"0000000000000000000000000000000000000000000000000000000000000000"},
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"signature": {"9WZi7vZfCzpEwDYohx0SGtJjj_l1Smr4YJa1S00aAMLT-zrsf9lWau4T22wNKdNadNuildxM-10aK9IGUU1NBA"},
+		"user":      {testWebAppUser},
+	}.Encode(), testBotID, fakeTelegramPublicKey)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if d.User == nil || d.User.ID != 12345678 {
+		t.Errorf("user ID should be 12345678, but user was %v", d.User)
+	}
+}
+
+func TestConvertAndVerifyWebAppInitDataSignature_WithWrongBotID(t *testing.T) {
+	_, err := ConvertAndVerifyWebAppInitDataSignature(url.Values{
+		"auth_date": {"1512345678"},
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"signature": {"9WZi7vZfCzpEwDYohx0SGtJjj_l1Smr4YJa1S00aAMLT-zrsf9lWau4T22wNKdNadNuildxM-10aK9IGUU1NBA"},
+		"user":      {testWebAppUser},
+	}.Encode(), testBotID+1, fakeTelegramPublicKey)
+	if err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyWebAppInitDataSignature_WithProductionKey(t *testing.T) {
+	_, err := ConvertAndVerifyWebAppInitDataSignature(url.Values{
+		"auth_date": {"1512345678"},
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"signature": {"9WZi7vZfCzpEwDYohx0SGtJjj_l1Smr4YJa1S00aAMLT-zrsf9lWau4T22wNKdNadNuildxM-10aK9IGUU1NBA"},
+		"user":      {testWebAppUser},
+	}.Encode(), testBotID, ProductionPublicKey())
+	if err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyWebAppInitDataSignature_WithoutSignature(t *testing.T) {
+	_, err := ConvertAndVerifyWebAppInitDataSignature(url.Values{
+		"auth_date": {"1512345678"},
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"user":      {testWebAppUser},
+	}.Encode(), testBotID, fakeTelegramPublicKey)
+	if err != ErrInvalidSignature {
+		t.Errorf("expected ErrInvalidSignature, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyWebAppInitDataSignature_WithWrongSizeKey(t *testing.T) {
+	for _, k := range []ed25519.PublicKey{nil, ProductionPublicKey()[:16]} {
+		if _, err := ConvertAndVerifyWebAppInitDataSignature(testInitData, testBotID, k); err == nil {
+			t.Errorf("key of %d bytes should be rejected, but wasn't", len(k))
+		}
+	}
+}
+
+func TestProductionPublicKey_ReturnsCopy(t *testing.T) {
+	ProductionPublicKey()[0] ^= 0xff
+	if k := ProductionPublicKey(); hex.EncodeToString(k) != productionPublicKeyHex {
+		t.Error("changing a returned key should not change the next one")
+	}
+}
+
+func TestConstructSignatureCheckString(t *testing.T) {
+	if s := constructSignatureCheckString(testBotID, []pair{
+		{"query_id", "AAHdF6IQAAAAAN0XohDhrOrc"},
+		{"auth_date", "1512345678"},
+	}); s != "123456789:WAWebAppData\nauth_date=1512345678\nquery_id=AAHdF6IQAAAAAN0XohDhrOrc" {
+		t.Errorf("result should be '123456789:WAWebAppData\nauth_date=1512345678\nquery_id=AAHdF6IQAAAAAN0XohDhrOrc', but was %v", s)
+	}
+}
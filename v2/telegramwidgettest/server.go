@@ -0,0 +1,215 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidgettest
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/wesleym/telegramwidget/v2"
+)
+
+// DefaultToken is a fake bot token for tests that don't need a particular one.
+const DefaultToken = "123456789:abcdefGHIJKLmnopqrSTUVWXyz123456789"
+
+// A Server is a fake of the Telegram login service for end-to-end tests that can't reach telegram.org. Instead of
+// asking for a phone number, its login page lists test users. Picking one redirects the browser back to the site with
+// the user's data signed for a fake bot token, so the site's real verification code can be exercised offline.
+//
+// Two flows are supported. The oauth.telegram.org flow starts at the URL returned by AuthURL and sends the user data
+// to return_to in a tgAuthResult fragment. The login widget flow starts at the URL returned by WidgetURL, or at the
+// link rendered by the script at ScriptURL, and sends the user data to the widget's data-auth-url in the query string.
+// The widget's data-onauth callback is not supported.
+//
+// The exported fields must not be changed after the server has handled its first request.
+type Server struct {
+	*httptest.Server
+
+	// Token is the fake bot token that user data is signed for.
+	Token string
+
+	// Users are the test users offered on the login page.
+	Users []telegramwidget.User
+
+	// Now returns the auth date to sign into user data. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// NewServer starts and returns a new Server that signs user data for token and offers users on its login page. If no
+// users are given, a single test user is offered. The caller should call Close when finished, to shut it down.
+func NewServer(token string, users ...telegramwidget.User) *Server {
+	if len(users) == 0 {
+		users = []telegramwidget.User{{ID: 1, FirstName: "Test", Username: "test_user"}}
+	}
+	s := &Server{Token: token, Users: users}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth", s.servePicker)
+	mux.HandleFunc("/auth/login", s.serveLogin)
+	mux.HandleFunc("/js/telegram-widget.js", s.serveScript)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// TokenHash returns the hash of s.Token, as accepted by the verification functions of package telegramwidget.
+func (s *Server) TokenHash() []byte {
+	return telegramwidget.HashBotToken(s.Token)
+}
+
+// AuthURL returns the URL of the login page for a, pointing at s instead of oauth.telegram.org. a.BotID is filled in
+// from s.Token if it is zero, and a.ReturnTo must be set.
+func (s *Server) AuthURL(a telegramwidget.AuthURL) (string, error) {
+	if a.BotID == 0 {
+		a.BotID = s.botID()
+	}
+	if a.ReturnTo == "" {
+		return "", errors.New("ReturnTo must be set")
+	}
+	u, err := a.URL()
+	if err != nil {
+		return "", err
+	}
+	return s.URL + "/auth?" + u.RawQuery, nil
+}
+
+// WidgetURL returns the URL of the login page for a login widget whose data-auth-url is authURL. authURL must be
+// absolute.
+func (s *Server) WidgetURL(authURL string) string {
+	return s.URL + "/auth?" + url.Values{"auth_url": {authURL}}.Encode()
+}
+
+// ScriptURL returns the URL of a stand-in for telegram-widget.js. It can replace telegramwidget.WidgetScript in the
+// page under test. Instead of a button, it renders a link to the login page for the script tag's data-auth-url.
+func (s *Server) ScriptURL() string {
+	return s.URL + "/js/telegram-widget.js"
+}
+
+func (s *Server) botID() int64 {
+	t, _ := telegramwidget.ParseBotToken(s.Token)
+	return t.BotID()
+}
+
+func (s *Server) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// checkQuery returns an error if q doesn't describe a login that s can complete.
+func (s *Server) checkQuery(q url.Values) error {
+	if q.Has("bot_id") && q.Get("bot_id") != strconv.FormatInt(s.botID(), 10) {
+		return fmt.Errorf("bot_id %q doesn't match the token", q.Get("bot_id"))
+	}
+	if q.Get("auth_url") == "" && q.Get("return_to") == "" {
+		return errors.New("one of auth_url and return_to must be set")
+	}
+	for _, k := range []string{"auth_url", "return_to"} {
+		if v := q.Get(k); v != "" {
+			if u, err := url.Parse(v); err != nil || !u.IsAbs() {
+				return fmt.Errorf("%s %q must be an absolute URL", k, v)
+			}
+		}
+	}
+	return nil
+}
+
+var pickerTemplate = template.Must(template.New("picker").Parse(`<!DOCTYPE html>
+<title>Log in to Telegram</title>
+<h1>Pick a test user</h1>
+<ul>
+{{range .}}<li><a href="{{.URL}}">{{.Name}}</a></li>
+{{end}}</ul>
+`))
+
+func (s *Server) servePicker(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if err := s.checkQuery(q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	type choice struct {
+		URL  string
+		Name string
+	}
+	choices := make([]choice, len(s.Users))
+	for i, u := range s.Users {
+		q.Set("user", strconv.Itoa(i))
+		name := strings.TrimSpace(u.FirstName + " " + u.LastName)
+		if u.Username != "" {
+			name += " (@" + u.Username + ")"
+		}
+		if name == "" {
+			name = strconv.FormatInt(u.ID, 10)
+		}
+		choices[i] = choice{URL: "/auth/login?" + q.Encode(), Name: name}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	pickerTemplate.Execute(w, choices)
+}
+
+func (s *Server) serveLogin(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if err := s.checkQuery(q); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	i, err := strconv.Atoi(q.Get("user"))
+	if err != nil || i < 0 || i >= len(s.Users) {
+		http.Error(w, "unknown user", http.StatusBadRequest)
+		return
+	}
+	u := s.Users[i]
+	u.AuthDate = s.now()
+
+	var target *url.URL
+	if authURL := q.Get("auth_url"); authURL != "" {
+		// Like Telegram, add the user data to whatever query the site already has.
+		target, _ = url.Parse(authURL)
+		tq := target.Query()
+		for k, vs := range Form(u, nil, s.TokenHash()) {
+			tq[k] = vs
+		}
+		target.RawQuery = tq.Encode()
+	} else {
+		target, _ = url.Parse(q.Get("return_to"))
+		target.Fragment = "tgAuthResult=" + AuthResult(u, nil, s.TokenHash())
+	}
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+const widgetScript = `(function () {
+  var script = document.currentScript;
+  var authURL = new URL(script.getAttribute('data-auth-url'), document.baseURI);
+  var link = document.createElement('a');
+  link.href = new URL(script.src).origin + '/auth?auth_url=' + encodeURIComponent(authURL.href);
+  link.textContent = 'Log in with Telegram';
+  script.parentNode.insertBefore(link, script);
+})();
+`
+
+func (s *Server) serveScript(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Write([]byte(widgetScript))
+}
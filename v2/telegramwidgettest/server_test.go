@@ -0,0 +1,122 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidgettest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/wesleym/telegramwidget/v2"
+)
+
+var choicePattern = regexp.MustCompile(`<a href="([^"]+)">([^<]+)</a>`)
+
+// pick fetches the login page at loginURL and returns the absolute URL of the link for the named user.
+func pick(t *testing.T, s *Server, loginURL, name string) string {
+	t.Helper()
+	resp, err := http.Get(loginURL)
+	if err != nil {
+		t.Fatalf("failed to get login page: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status should be 200, but was %d: %s", resp.StatusCode, body)
+	}
+	for _, m := range choicePattern.FindAllStringSubmatch(string(body), -1) {
+		if m[2] == name {
+			return s.URL + strings.ReplaceAll(m[1], "&amp;", "&")
+		}
+	}
+	t.Fatalf("login page has no link for %q: %s", name, body)
+	return ""
+}
+
+func TestServer_Widget(t *testing.T) {
+	s := NewServer(DefaultToken, testUser)
+	defer s.Close()
+
+	var got telegramwidget.User
+	mux := http.NewServeMux()
+	mux.Handle("/login", telegramwidget.NewLoginHandler(s.TokenHash(), func(w http.ResponseWriter, r *http.Request, u telegramwidget.User) error {
+		got = u
+		return nil
+	}))
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+	app := httptest.NewServer(mux)
+	defer app.Close()
+
+	resp, err := http.Get(pick(t, s, s.WidgetURL(app.URL+"/login"), "John 🕶 Smith (@jsmith)"))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status should be 200, but was %d", resp.StatusCode)
+	}
+	if got.ID != testUser.ID {
+		t.Errorf("logged in user should be %d, but was %d", testUser.ID, got.ID)
+	}
+}
+
+func TestServer_AuthURL(t *testing.T) {
+	s := NewServer(DefaultToken, testUser)
+	defer s.Close()
+
+	loginURL, err := s.AuthURL(telegramwidget.AuthURL{Origin: "https://example.com", ReturnTo: "https://example.com/login"})
+	if err != nil {
+		t.Fatalf("failed to build auth URL: %v", err)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(pick(t, s, loginURL, "John 🕶 Smith (@jsmith)"))
+	if err != nil {
+		t.Fatalf("failed to log in: %v", err)
+	}
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("failed to parse location: %v", err)
+	}
+	if location.Host != "example.com" || location.Path != "/login" {
+		t.Errorf("location should be https://example.com/login, but was %v", location)
+	}
+	u, err := telegramwidget.ConvertAndVerifyAuthResult(strings.TrimPrefix(location.Fragment, "tgAuthResult="), s.TokenHash())
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if u.Username != "jsmith" {
+		t.Errorf("username should be jsmith, but was %v", u.Username)
+	}
+}
+
+func TestServer_WithWrongBotID(t *testing.T) {
+	s := NewServer(DefaultToken)
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/auth?bot_id=1&origin=https%3A%2F%2Fexample.com&return_to=https%3A%2F%2Fexample.com%2F")
+	if err != nil {
+		t.Fatalf("failed to get login page: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status should be 400, but was %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package telegramwidgettest provides utilities for testing code that uses package telegramwidget. It produces signed
+// user data in each of the formats that Telegram sends, so that tests don't need to hard-code hashes.
+package telegramwidgettest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"github.com/wesleym/telegramwidget/v2"
+)
+
+// Values returns the fields of u and extra as Telegram would send them, without a hash. Optional fields are included
+// if they are marked present in u.Fields or are not empty. Fields in extra take precedence over those in u.Extra.
+func Values(u telegramwidget.User, extra map[string]string) url.Values {
+	f := url.Values{
+		"id":        {strconv.FormatInt(u.ID, 10)},
+		"auth_date": {strconv.FormatInt(u.AuthDate.Unix(), 10)},
+	}
+	if u.FirstName != "" || u.Has(telegramwidget.FieldFirstName) {
+		f.Set("first_name", u.FirstName)
+	}
+	if u.LastName != "" || u.Has(telegramwidget.FieldLastName) {
+		f.Set("last_name", u.LastName)
+	}
+	if u.PhotoURL != nil {
+		f.Set("photo_url", u.PhotoURL.String())
+	}
+	if u.Username != "" || u.Has(telegramwidget.FieldUsername) {
+		f.Set("username", u.Username)
+	}
+	for k, v := range u.Extra {
+		f.Set(k, v)
+	}
+	for k, v := range extra {
+		f.Set(k, v)
+	}
+	return f
+}
+
+// Form returns u and extra signed with tokenHash, as sent in the query string by the data-auth-url redirect. The
+// result can be verified with telegramwidget.ConvertAndVerifyForm.
+func Form(u telegramwidget.User, extra map[string]string, tokenHash []byte) url.Values {
+	f, err := telegramwidget.SignForm(Values(u, extra), tokenHash)
+	if err != nil {
+		// Values never produces more than one value for a key.
+		panic(err)
+	}
+	return f
+}
+
+// JSON returns u and extra signed with tokenHash, as passed to the data-onauth callback. The result can be verified
+// with telegramwidget.ConvertAndVerifyJSON.
+func JSON(u telegramwidget.User, extra map[string]string, tokenHash []byte) []byte {
+	return EncodeJSON(Form(u, extra, tokenHash))
+}
+
+// EncodeJSON encodes the first value of each field in f as a JSON object, in the form passed to the data-onauth
+// callback. The ID and auth date are encoded as numbers if they are integers, and everything else as strings. It
+// doesn't sign f, so f should come from Form or telegramwidget.SignForm.
+func EncodeJSON(f url.Values) []byte {
+	keys := make([]string, 0, len(f))
+	for k := range f {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// Encoding by hand keeps the keys in a stable order and the numbers as numbers.
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		name, _ := json.Marshal(k)
+		b.Write(name)
+		b.WriteByte(':')
+		if _, err := strconv.ParseInt(f.Get(k), 10, 64); err == nil && (k == "id" || k == "auth_date") {
+			b.WriteString(f.Get(k))
+		} else {
+			value, _ := json.Marshal(f.Get(k))
+			b.Write(value)
+		}
+	}
+	b.WriteByte('}')
+	return b.Bytes()
+}
+
+// AuthResult returns u and extra signed with tokenHash, as sent in the tgAuthResult fragment by oauth.telegram.org.
+// The result can be verified with telegramwidget.ConvertAndVerifyAuthResult.
+func AuthResult(u telegramwidget.User, extra map[string]string, tokenHash []byte) string {
+	return base64.RawURLEncoding.EncodeToString(JSON(u, extra, tokenHash))
+}
+
+// WebAppInitData returns d and extra signed with secretKey, as found in Telegram.WebApp.initData. The secret key
+// should come from telegramwidget.WebAppSecretKey. The result can be verified with
+// telegramwidget.ConvertAndVerifyWebAppInitData.
+func WebAppInitData(d telegramwidget.WebAppInitData, extra map[string]string, secretKey []byte) (string, error) {
+	f := url.Values{"auth_date": {strconv.FormatInt(d.AuthDate.Unix(), 10)}}
+	set := func(k, v string) {
+		if v != "" {
+			f.Set(k, v)
+		}
+	}
+	set("query_id", d.QueryID)
+	set("chat_type", d.ChatType)
+	set("chat_instance", d.ChatInstance)
+	set("start_param", d.StartParam)
+	if d.CanSendAfter != 0 {
+		f.Set("can_send_after", strconv.FormatInt(int64(d.CanSendAfter.Seconds()), 10))
+	}
+	for _, o := range []struct {
+		k       string
+		v       interface{}
+		present bool
+	}{
+		{"user", d.User, d.User != nil},
+		{"receiver", d.Receiver, d.Receiver != nil},
+		{"chat", d.Chat, d.Chat != nil},
+	} {
+		if !o.present {
+			continue
+		}
+		b, err := json.Marshal(o.v)
+		if err != nil {
+			return "", err
+		}
+		f.Set(o.k, string(b))
+	}
+	for k, v := range extra {
+		f.Set(k, v)
+	}
+
+	signed, err := telegramwidget.SignForm(f, secretKey)
+	if err != nil {
+		return "", err
+	}
+	return signed.Encode(), nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidgettest
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/wesleym/telegramwidget/v2"
+)
+
+var testUser = telegramwidget.User{
+	AuthDate:  time.Unix(1512345678, 0),
+	FirstName: "John 🕶",
+	ID:        12345678,
+	LastName:  "Smith",
+	PhotoURL:  &url.URL{Scheme: "https", Host: "t.me", Path: "/i/userpic/320/jsmith.jpg"},
+	Username:  "jsmith",
+}
+
+func TestForm_MatchesTelegram(t *testing.T) {
+	// This hash was produced by Telegram's algorithm independently of this package.
+	f := Form(testUser, nil, telegramwidget.HashBotToken(DefaultToken))
+	if h := f.Get("hash"); h != "25409759c10beb29bd3f3fe1d16ee0605ac82eb2907d886e196d481371b91501" {
+		t.Errorf("hash should be 25409759..., but was %v", h)
+	}
+}
+
+func TestForm_Verifies(t *testing.T) {
+	tokenHash := telegramwidget.HashBotToken(DefaultToken)
+	u, err := telegramwidget.ConvertAndVerifyForm(Form(testUser, map[string]string{"allows_write_to_pm": "true"}, tokenHash), tokenHash)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if u.Extra["allows_write_to_pm"] != "true" {
+		t.Errorf("extra field should be true, but was %v", u.Extra["allows_write_to_pm"])
+	}
+}
+
+func TestJSON_Verifies(t *testing.T) {
+	tokenHash := telegramwidget.HashBotToken(DefaultToken)
+	u, err := telegramwidget.ConvertAndVerifyJSON(bytes.NewReader(JSON(testUser, nil, tokenHash)), tokenHash)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if u.ID != testUser.ID || u.Username != testUser.Username {
+		t.Errorf("user should be %v, but was %v", testUser, u)
+	}
+}
+
+func TestAuthResult_Verifies(t *testing.T) {
+	tokenHash := telegramwidget.HashBotToken(DefaultToken)
+	if _, err := telegramwidget.ConvertAndVerifyAuthResult(AuthResult(testUser, nil, tokenHash), tokenHash); err != nil {
+		t.Errorf("failed to convert and verify: %v", err)
+	}
+}
+
+func TestWebAppInitData_Verifies(t *testing.T) {
+	key := telegramwidget.WebAppSecretKey(DefaultToken)
+	initData, err := WebAppInitData(telegramwidget.WebAppInitData{
+		QueryID:      "AAHdF6IQAAAAAN0XohDhrOrc",
+		User:         &telegramwidget.WebAppUser{ID: 12345678, FirstName: "John", Username: "jsmith"},
+		Chat:         &telegramwidget.WebAppChat{ID: -100, Type: "group", Title: "Friends"},
+		CanSendAfter: 10 * time.Second,
+		AuthDate:     time.Unix(1512345678, 0),
+	}, nil, key)
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+	d, err := telegramwidget.ConvertAndVerifyWebAppInitData(initData, key)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if d.User == nil || d.User.Username != "jsmith" || d.Chat == nil || d.Chat.Title != "Friends" || d.Receiver != nil {
+		t.Errorf("unexpected init data %+v", d)
+	}
+	if d.CanSendAfter != 10*time.Second {
+		t.Errorf("can send after should be 10s, but was %v", d.CanSendAfter)
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidBotToken indicates that a string is not a bot token of the form "<bot_id>:<secret>". Errors wrapping it
+// never include the token.
+var ErrInvalidBotToken = errors.New("invalid bot token")
+
+// redacted replaces the secret part of a bot token when it is printed.
+const redacted = "[redacted]"
+
+// A BotToken is a bot token as issued by BotFather, of the form "<bot_id>:<secret>". Unlike a string, it can't be
+// printed, formatted or encoded with its secret: only the bot ID is shown, so a BotToken that ends up in a log or a
+// panic doesn't leak. The full token is only returned by Reveal.
+//
+// The keys derived from a BotToken are computed once and shared by its copies.
+//
+// The zero BotToken is not valid. Use ParseBotToken to create one.
+type BotToken struct {
+	id int64
+	// secret is behind two pointers because fmt can't call the methods of a BotToken in an unexported field of another
+	// struct, and prints its fields instead. For a verb such as %s that doesn't apply to pointers, fmt prints what a
+	// pointer refers to, which for a single pointer would be the botSecret. For a pointer to a pointer, it prints only
+	// an address.
+	secret **botSecret
+}
+
+type botSecret struct {
+	token string
+
+	once      sync.Once
+	hash      []byte
+	webAppKey []byte
+}
+
+// keys returns the keys derived from the token, computing them on first use.
+func (s *botSecret) keys() (hash, webAppKey []byte) {
+	s.once.Do(func() {
+		s.hash = HashBotToken(s.token)
+		s.webAppKey = WebAppSecretKey(s.token)
+	})
+	return s.hash, s.webAppKey
+}
+
+// ParseBotToken parses s as a bot token. It returns an error wrapping ErrInvalidBotToken if s isn't of the form
+// "<bot_id>:<secret>", where the bot ID is a positive integer and the secret is made of letters, digits, "_" and "-".
+func ParseBotToken(s string) (BotToken, error) {
+	idPart, secret, ok := strings.Cut(s, ":")
+	if !ok {
+		return BotToken{}, fmt.Errorf("%w: missing colon", ErrInvalidBotToken)
+	}
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil || id <= 0 || idPart[0] == '+' {
+		return BotToken{}, fmt.Errorf("%w: bot ID is not a positive integer", ErrInvalidBotToken)
+	}
+	if secret == "" {
+		return BotToken{}, fmt.Errorf("%w: empty secret", ErrInvalidBotToken)
+	}
+	for _, c := range secret {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '-') {
+			// The offending character is part of the secret, so it isn't included.
+			return BotToken{}, fmt.Errorf("%w: unexpected character in secret", ErrInvalidBotToken)
+		}
+	}
+	bs := &botSecret{token: s}
+	return BotToken{id: id, secret: &bs}, nil
+}
+
+// BotID returns the numeric ID of the bot, which is the part of the token before the colon. It is needed for AuthURL
+// and ConvertAndVerifyWebAppInitDataSignature.
+func (t BotToken) BotID() int64 {
+	return t.id
+}
+
+// Reveal returns the full token. It should only be passed to the Bot API, and never logged.
+func (t BotToken) Reveal() string {
+	if t.secret == nil {
+		return ""
+	}
+	return (*t.secret).token
+}
+
+// Hash returns the key that login widget data is signed with, as HashBotToken does. It returns nil for the zero
+// BotToken, which no data verifies against.
+func (t BotToken) Hash() []byte {
+	if t.secret == nil {
+		return nil
+	}
+	hash, _ := (*t.secret).keys()
+	// The cached key is copied so that callers can't change it for everyone else.
+	return append([]byte(nil), hash...)
+}
+
+// WebAppSecretKey returns the key that Mini App initData is signed with, as the package-level WebAppSecretKey does. It
+// returns nil for the zero BotToken.
+func (t BotToken) WebAppSecretKey() []byte {
+	if t.secret == nil {
+		return nil
+	}
+	_, webAppKey := (*t.secret).keys()
+	return append([]byte(nil), webAppKey...)
+}
+
+// String returns the token with its secret redacted.
+func (t BotToken) String() string {
+	if t.secret == nil {
+		return ""
+	}
+	return strconv.FormatInt(t.id, 10) + ":" + redacted
+}
+
+// GoString returns the token with its secret redacted, for the %#v verb.
+func (t BotToken) GoString() string {
+	return "telegramwidget.BotToken(" + strconv.Quote(t.String()) + ")"
+}
+
+// Format formats the token with its secret redacted for every verb, so that verbs such as %x, which would otherwise
+// bypass String, can't print it.
+func (t BotToken) Format(f fmt.State, verb rune) {
+	switch {
+	case verb == 'v' && f.Flag('#'):
+		fmt.Fprint(f, t.GoString())
+	case verb == 'q':
+		fmt.Fprint(f, strconv.Quote(t.String()))
+	default:
+		fmt.Fprint(f, t.String())
+	}
+}
+
+// MarshalText returns the token with its secret redacted. It is also used when the token is encoded as JSON.
+func (t BotToken) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
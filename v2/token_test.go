@@ -0,0 +1,106 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseBotToken(t *testing.T) {
+	token, err := ParseBotToken(testBotToken)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	if token.BotID() != 123456789 {
+		t.Errorf("bot ID should be 123456789, but was %d", token.BotID())
+	}
+	if token.Reveal() != testBotToken {
+		t.Errorf("revealed token should be the input, but was %q", token.Reveal())
+	}
+	if !bytes.Equal(token.Hash(), testBotTokenHash) {
+		t.Errorf("hash should match HashBotToken, but was %x", token.Hash())
+	}
+	if !bytes.Equal(token.WebAppSecretKey(), WebAppSecretKey(testBotToken)) {
+		t.Errorf("Mini App key should match WebAppSecretKey, but was %x", token.WebAppSecretKey())
+	}
+
+	// The keys are cached, so changing a returned one must not affect the next.
+	token.Hash()[0] ^= 0xff
+	if !bytes.Equal(token.Hash(), testBotTokenHash) {
+		t.Errorf("hash should be unaffected by changes to a returned hash, but was %x", token.Hash())
+	}
+}
+
+func TestParseBotToken_WithMalformedToken(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"abcdefGHIJKLmnopqrSTUVWXyz123456789",
+		":abcdefGHIJKLmnopqrSTUVWXyz123456789",
+		"bot:abcdefGHIJKLmnopqrSTUVWXyz123456789",
+		"-1:abcdefGHIJKLmnopqrSTUVWXyz123456789",
+		"+1:abcdefGHIJKLmnopqrSTUVWXyz123456789",
+		"123456789:",
+		"123456789:abcdefGHIJKLmnopqrSTUVWXyz123456789\n",
+		"123456789:abc:def",
+	} {
+		_, err := ParseBotToken(s)
+		if !errors.Is(err, ErrInvalidBotToken) {
+			t.Errorf("expected ErrInvalidBotToken for %q, but was %v", s, err)
+		} else if s != "" && strings.Contains(err.Error(), s) {
+			t.Errorf("error for %q should not contain the token, but was %q", s, err)
+		}
+	}
+}
+
+func TestBotToken_IsRedacted(t *testing.T) {
+	token, err := ParseBotToken(testBotToken)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	secret := strings.SplitN(testBotToken, ":", 2)[1]
+	// The derived keys are computed first so that they would be printed if the secret were reachable.
+	hash := token.Hash()
+
+	var outputs []string
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d"} {
+		outputs = append(outputs, fmt.Sprintf(format, token))
+		outputs = append(outputs, fmt.Sprintf(format, struct{ t BotToken }{token}))
+		outputs = append(outputs, fmt.Sprintf(format, struct{ T BotToken }{token}))
+	}
+	outputs = append(outputs, token.String(), token.GoString())
+	b, err := json.Marshal(struct{ Token BotToken }{token})
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	outputs = append(outputs, string(b))
+
+	for _, o := range outputs {
+		if strings.Contains(o, secret) || strings.Contains(o, fmt.Sprintf("%x", secret)) ||
+			strings.Contains(o, fmt.Sprintf("%x", hash)) || strings.Contains(o, fmt.Sprint(hash)) {
+			t.Errorf("output should be redacted, but was %q", o)
+		}
+	}
+	if want := `{"Token":"123456789:[redacted]"}`; string(b) != want {
+		t.Errorf("JSON should be %s, but was %s", want, b)
+	}
+	if s := fmt.Sprint(token); s != "123456789:[redacted]" {
+		t.Errorf("token should print as 123456789:[redacted], but was %q", s)
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A TokenSource supplies the current bot token, so that the token can be kept in a secret store and rotated without
+// restarting the process. A Verifier with a TokenSource consults it for each verification, so Token should be cheap
+// and return the same BotToken until the token changes, which lets the keys derived from it be reused.
+//
+// Implementations must be safe for concurrent use.
+type TokenSource interface {
+	Token() (BotToken, error)
+}
+
+// StaticTokenSource returns a TokenSource that always returns t.
+func StaticTokenSource(t BotToken) TokenSource {
+	return staticTokenSource{t}
+}
+
+type staticTokenSource struct {
+	t BotToken
+}
+
+func (s staticTokenSource) Token() (BotToken, error) {
+	return s.t, nil
+}
+
+// EnvTokenSource returns a TokenSource that reads the token from the environment variable name each time it is
+// consulted. The token is only parsed again when the variable changes.
+func EnvTokenSource(name string) TokenSource {
+	return &envTokenSource{name: name}
+}
+
+type envTokenSource struct {
+	name string
+
+	mu    sync.Mutex
+	raw   string
+	token BotToken
+}
+
+func (s *envTokenSource) Token() (BotToken, error) {
+	raw := os.Getenv(s.name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if raw == s.raw && raw != "" {
+		return s.token, nil
+	}
+	if raw == "" {
+		return BotToken{}, fmt.Errorf("%s is not set", s.name)
+	}
+	t, err := ParseBotToken(strings.TrimSpace(raw))
+	if err != nil {
+		return BotToken{}, fmt.Errorf("%s: %w", s.name, err)
+	}
+	s.raw, s.token = raw, t
+	return t, nil
+}
+
+// DefaultTokenCheckInterval is how often a FileTokenSource checks its file for changes by default.
+const DefaultTokenCheckInterval = 10 * time.Second
+
+// A FileTokenSource is a TokenSource that reads the token from a file, such as a Kubernetes secret mount, and reloads
+// it when the file changes. Leading and trailing white space in the file is ignored.
+//
+// The file is read again at most once every check interval, and the token is only parsed again if the file has
+// changed. If the file can't be read or doesn't hold a valid token, perhaps because it is still being written, the
+// previous token continues to be returned and the file is read again at the next check.
+type FileTokenSource struct {
+	path          string
+	checkInterval time.Duration
+	now           func() time.Time
+
+	mu      sync.Mutex
+	raw     string
+	token   BotToken
+	checked time.Time
+}
+
+// NewFileTokenSource returns a FileTokenSource for the file at path that checks for changes every checkInterval. If
+// checkInterval is zero, DefaultTokenCheckInterval is used. It returns an error if the file can't be read or doesn't
+// hold a valid token.
+func NewFileTokenSource(path string, checkInterval time.Duration) (*FileTokenSource, error) {
+	return newFileTokenSource(path, checkInterval, time.Now)
+}
+
+func newFileTokenSource(path string, checkInterval time.Duration, now func() time.Time) (*FileTokenSource, error) {
+	if checkInterval == 0 {
+		checkInterval = DefaultTokenCheckInterval
+	}
+	s := &FileTokenSource{path: path, checkInterval: checkInterval, now: now}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Token returns the token from the file, reloading it if the file has changed since it was last checked.
+func (s *FileTokenSource) Token() (BotToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.now().Sub(s.checked) >= s.checkInterval {
+		// A failed reload keeps the previous token, which is still the best one available.
+		_ = s.load()
+	}
+	return s.token, nil
+}
+
+// load reads the file and parses it if it has changed. It must be called with s.mu held, or before s is shared.
+func (s *FileTokenSource) load() error {
+	s.checked = s.now()
+	// The file is small, so reading it is as cheap as checking whether it changed. ReadFile follows symbolic links, so
+	// it sees the new file when a Kubernetes secret mount swaps its link.
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		return err
+	}
+	raw := string(b)
+	if raw == s.raw {
+		return nil
+	}
+	t, err := ParseBotToken(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.path, err)
+	}
+	s.raw, s.token = raw, t
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const otherBotToken = "987654321:zyxwvuTSRQPONMLKJIHGFEDCBAabcdefghi"
+
+func TestEnvTokenSource(t *testing.T) {
+	const name = "TELEGRAMWIDGET_TEST_TOKEN"
+	s := EnvTokenSource(name)
+
+	t.Setenv(name, "")
+	if _, err := s.Token(); err == nil {
+		t.Error("should have returned error for unset variable, but was nil")
+	}
+
+	t.Setenv(name, testBotToken)
+	first, err := s.Token()
+	if err != nil {
+		t.Fatalf("failed to get token: %v", err)
+	}
+	if again, _ := s.Token(); again != first {
+		t.Error("token should be reused while the variable is unchanged, but was parsed again")
+	}
+
+	t.Setenv(name, otherBotToken)
+	if tok, err := s.Token(); err != nil || tok.BotID() != 987654321 {
+		t.Errorf("token should have been reloaded, but was %v, %v", tok, err)
+	}
+}
+
+func TestFileTokenSource_Reloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte(testBotToken+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1512345678, 0)
+	s, err := newFileTokenSource(path, time.Minute, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(otherBotToken), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if tok, _ := s.Token(); tok.BotID() != 123456789 {
+		t.Errorf("token should not be reloaded before the check interval, but was %v", tok)
+	}
+
+	now = now.Add(time.Minute)
+	if tok, _ := s.Token(); tok.BotID() != 987654321 {
+		t.Errorf("token should be reloaded after the check interval, but was %v", tok)
+	}
+
+	// A half-written file keeps the previous token.
+	if err := os.WriteFile(path, []byte("98765"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Minute)
+	if tok, err := s.Token(); err != nil || tok.BotID() != 987654321 {
+		t.Errorf("token should be kept when the file is invalid, but was %v, %v", tok, err)
+	}
+}
+
+func TestNewFileTokenSource_WithMissingFile(t *testing.T) {
+	if _, err := NewFileTokenSource(filepath.Join(t.TempDir(), "missing"), 0); err == nil {
+		t.Error("should have returned error, but was nil")
+	}
+}
+
+func TestVerifier_WithTokenSource(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte(otherBotToken), 0600); err != nil {
+		t.Fatal(err)
+	}
+	now := testAuthDate.Add(time.Minute)
+	s, err := newFileTokenSource(path, time.Second, func() time.Time { return now })
+	if err != nil {
+		t.Fatalf("failed to create source: %v", err)
+	}
+	v := Verifier{TokenSource: s, Now: func() time.Time { return now }}
+
+	if _, err := v.ConvertAndVerifyForm(testForm); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash before rotation, but was %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(testBotToken), 0600); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(time.Second)
+	if _, err := v.ConvertAndVerifyForm(testForm); err != nil {
+		t.Errorf("failed to convert and verify after rotation: %v", err)
+	}
+}
+
+func TestWebAppAuthenticator_WithTokenSource(t *testing.T) {
+	tok, err := ParseBotToken(testBotToken)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+	a := WebAppAuthenticator(nil, &Verifier{TokenSource: StaticTokenSource(tok)})
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Authorization", "tma "+testInitData)
+	if _, err := a(r); err != nil {
+		t.Errorf("failed to authenticate: %v", err)
+	}
+}
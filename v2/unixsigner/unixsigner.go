@@ -0,0 +1,201 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package unixsigner is a reference implementation of a signing daemon for package telegramwidget. A Server holds the
+// key and computes MACs for clients that connect to it over a Unix socket, and a Client is a
+// telegramwidget.MACComputer that asks a Server for them. That way the key never enters the memory of the web tier.
+//
+// The Server will compute the MAC of anything it is sent, so any process that can connect to its socket can forge
+// user data. The socket's permissions must only admit the processes that verify data. What the Server protects
+// against is the key itself being read out of a compromised web tier and used elsewhere.
+//
+// A signing daemon might look like this, where TokenSourceMAC consults the source for each MAC so that the daemon picks
+// up a rotated token without restarting:
+//
+//	source, err := telegramwidget.NewFileTokenSource("/run/secrets/bot-token", 0)
+//	...
+//	l, err := net.Listen("unix", "/run/telegram-signer.sock")
+//	...
+//	log.Fatal((&unixsigner.Server{MAC: telegramwidget.TokenSourceMAC(source)}).Serve(l))
+//
+// and the web tier would verify data with:
+//
+//	v := &telegramwidget.Verifier{MAC: &unixsigner.Client{Path: "/run/telegram-signer.sock"}, MaxAge: time.Hour}
+//
+// The protocol is private to this package and may change. Each request is a 4-byte big-endian length followed by the
+// data-check-string. Each response is a status byte, 0 for success or 1 for failure, followed by a 4-byte big-endian
+// length and either the MAC or an error message. A connection may carry any number of requests in turn.
+package unixsigner
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"github.com/wesleym/telegramwidget/v2"
+)
+
+// MaxMessageBytes is the largest data-check-string that a Server accepts, and the largest response that a Client
+// accepts.
+const MaxMessageBytes = 64 << 10
+
+// DefaultTimeout is how long a Client waits for a Server by default.
+const DefaultTimeout = 5 * time.Second
+
+const (
+	statusOK    = 0
+	statusError = 1
+)
+
+// A Client is a telegramwidget.MACComputer that asks the Server listening on a Unix socket for each MAC. It opens a
+// new connection for each MAC, since logins are rare compared to other requests.
+type Client struct {
+	// Path is the path of the Server's socket.
+	Path string
+
+	// Timeout bounds the time taken to connect to the Server and get a MAC. If Timeout is zero, DefaultTimeout is
+	// used.
+	Timeout time.Duration
+}
+
+// MAC asks the Server for the MAC of checkString.
+func (c *Client) MAC(checkString []byte) ([]byte, error) {
+	if len(checkString) > MaxMessageBytes {
+		return nil, fmt.Errorf("data-check-string of %d bytes exceeds %d", len(checkString), MaxMessageBytes)
+	}
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	conn, err := net.DialTimeout("unix", c.Path, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	if err := writeMessage(conn, nil, checkString); err != nil {
+		return nil, err
+	}
+	var status [1]byte
+	if _, err := io.ReadFull(conn, status[:]); err != nil {
+		return nil, err
+	}
+	b, err := readMessage(conn)
+	if err != nil {
+		return nil, err
+	}
+	if status[0] != statusOK {
+		return nil, fmt.Errorf("signer: %s", b)
+	}
+	return b, nil
+}
+
+// A Server computes MACs for Clients.
+type Server struct {
+	// MAC computes each MAC, typically with telegramwidget.KeyMAC.
+	MAC telegramwidget.MACComputer
+
+	// IdleTimeout is how long a connection may wait between requests before it is closed. If IdleTimeout is zero,
+	// DefaultTimeout is used.
+	IdleTimeout time.Duration
+}
+
+// Serve accepts connections on l and answers the requests on each until l is closed. It returns nil if l was closed,
+// and otherwise the error from accepting a connection. Like http.Server, it retries temporary errors, such as running
+// out of file descriptors, after a delay that grows up to a second.
+func (s *Server) Serve(l net.Listener) error {
+	var delay time.Duration
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if ne, ok := err.(net.Error); ok && ne.Temporary() {
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else {
+				delay *= 2
+			}
+			if delay > time.Second {
+				delay = time.Second
+			}
+			time.Sleep(delay)
+			continue
+		} else if err != nil {
+			return err
+		}
+		delay = 0
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	timeout := s.IdleTimeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	for {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return
+		}
+		checkString, err := readMessage(conn)
+		if err != nil {
+			// The connection is closed or broken, or the client sent too much. Either way, it can't continue.
+			return
+		}
+		mac, err := s.MAC.MAC(checkString)
+		if err != nil {
+			err = writeMessage(conn, []byte{statusError}, []byte(err.Error()))
+		} else {
+			err = writeMessage(conn, []byte{statusOK}, mac)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeMessage writes prefix, then the length of b, then b.
+func writeMessage(w io.Writer, prefix, b []byte) error {
+	msg := make([]byte, 0, len(prefix)+4+len(b))
+	msg = append(msg, prefix...)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(len(b)))
+	msg = append(msg, b...)
+	_, err := w.Write(msg)
+	return err
+}
+
+// readMessage reads a length, then that many bytes.
+func readMessage(r io.Reader) ([]byte, error) {
+	var n [4]byte
+	if _, err := io.ReadFull(r, n[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(n[:])
+	if size > MaxMessageBytes {
+		return nil, fmt.Errorf("message of %d bytes exceeds %d", size, MaxMessageBytes)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package unixsigner
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/wesleym/telegramwidget/v2"
+	"github.com/wesleym/telegramwidget/v2/telegramwidgettest"
+)
+
+type failingMAC struct{}
+
+func (failingMAC) MAC([]byte) ([]byte, error) {
+	return nil, errors.New("key unavailable")
+}
+
+// startServer starts a Server with m on a new socket and returns its path.
+func startServer(t *testing.T, m telegramwidget.MACComputer) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "signer.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("Unix sockets are unavailable: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- (&Server{MAC: m}).Serve(l) }()
+	t.Cleanup(func() {
+		l.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve should return nil once closed, but was %v", err)
+		}
+	})
+	return path
+}
+
+// flakyListener fails to accept with a temporary error a number of times before accepting from the embedded Listener.
+type flakyListener struct {
+	net.Listener
+	failures int
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if l.failures > 0 {
+		l.failures--
+		return nil, temporaryError{}
+	}
+	return l.Listener.Accept()
+}
+
+func TestServer_RetriesTemporaryErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signer.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("Unix sockets are unavailable: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- (&Server{MAC: telegramwidget.KeyMAC([]byte("key"))}).Serve(&flakyListener{l, 3}) }()
+	defer func() {
+		l.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Serve should return nil once closed, but was %v", err)
+		}
+	}()
+	if _, err := (&Client{Path: path}).MAC([]byte("auth_date=1\nid=1")); err != nil {
+		t.Errorf("failed to get MAC after temporary errors: %v", err)
+	}
+}
+
+func TestClient_Verifies(t *testing.T) {
+	token, err := telegramwidget.ParseBotToken(telegramwidgettest.DefaultToken)
+	if err != nil {
+		t.Fatalf("failed to parse token: %v", err)
+	}
+	path := startServer(t, telegramwidget.KeyMAC(token.Hash()))
+	c := &Client{Path: path}
+
+	f := telegramwidgettest.Form(telegramwidget.User{ID: 1, Username: "ann"}, nil, token.Hash())
+	u, err := telegramwidget.ConvertAndVerifyFormWith(f, c)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if u.Username != "ann" {
+		t.Errorf("username should be ann, but was %v", u.Username)
+	}
+
+	f.Set("username", "bob")
+	if _, err := telegramwidget.ConvertAndVerifyFormWith(f, c); err != telegramwidget.ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestClient_WithFailingSigner(t *testing.T) {
+	c := &Client{Path: startServer(t, failingMAC{})}
+	_, err := c.MAC([]byte("auth_date=1\nid=1"))
+	if err == nil || !strings.Contains(err.Error(), "key unavailable") {
+		t.Errorf("expected the signer's error, but was %v", err)
+	}
+}
+
+func TestClient_WithoutServer(t *testing.T) {
+	c := &Client{Path: filepath.Join(t.TempDir(), "missing.sock")}
+	if _, err := c.MAC([]byte("auth_date=1\nid=1")); err == nil {
+		t.Error("should have returned error, but was nil")
+	}
+}
+
+func TestClient_WithTooLongCheckString(t *testing.T) {
+	c := &Client{Path: startServer(t, failingMAC{})}
+	if _, err := c.MAC(make([]byte, MaxMessageBytes+1)); err == nil {
+		t.Error("should have returned error, but was nil")
+	}
+}
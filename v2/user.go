@@ -23,7 +23,15 @@ import (
 // widget is represented in this type.
 //
 // Absent fields are parsed as their zero values. For example, when username is
-// not provided, the Username field contains the empty string.
+// not provided, the Username field contains the empty string. Fields records
+// which of the optional fields were present, so that an absent username can be
+// told apart from an empty one.
+//
+// Fields that this library doesn't know about are still verified, and are
+// kept in Extra by name. Extra is nil if there were no such fields.
+//
+// KeyID is the ID of the Key that verified the data when it was verified with
+// a KeyRing, and is empty otherwise.
 type User struct {
 	AuthDate  time.Time
 	FirstName string
@@ -31,4 +39,25 @@ type User struct {
 	LastName  string
 	PhotoURL  *url.URL
 	Username  string
+	Extra     map[string]string
+	Fields    Fields
+	KeyID     string
+}
+
+// Fields is a set of the optional fields of a User.
+type Fields uint8
+
+// These are the optional fields of a User. The ID and auth date are always
+// present.
+const (
+	FieldFirstName Fields = 1 << iota
+	FieldLastName
+	FieldPhotoURL
+	FieldUsername
+)
+
+// Has reports whether all of the fields in f were present in the data that u
+// was parsed from.
+func (u User) Has(f Fields) bool {
+	return u.Fields&f == f
 }
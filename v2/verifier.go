@@ -0,0 +1,213 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ErrExpired indicates that the data received was authenticated, but its auth date is older than allowed.
+var ErrExpired = errors.New("the auth date is too old")
+
+// ErrFromFuture indicates that the data received was authenticated, but its auth date is further in the future than
+// clock skew can account for.
+var ErrFromFuture = errors.New("the auth date is in the future")
+
+// ErrUnknownField indicates that the data received contained a field that this library doesn't know about, and the
+// Verifier was configured to disallow them.
+var ErrUnknownField = errors.New("unknown field in data")
+
+// An AuthDateError is returned when the auth date of authenticated data is unacceptable. Err is either ErrExpired or
+// ErrFromFuture, so callers can test for either with errors.Is.
+type AuthDateError struct {
+	AuthDate time.Time
+	Err      error
+}
+
+func (e *AuthDateError) Error() string {
+	return fmt.Sprintf("%v: %v", e.Err, e.AuthDate.UTC().Format(time.RFC3339))
+}
+
+func (e *AuthDateError) Unwrap() error {
+	return e.Err
+}
+
+// A Verifier parses and verifies data from the Telegram login widget like ConvertAndVerifyForm and
+// ConvertAndVerifyJSON, and additionally checks that the data was issued recently. Without such a check, data that was
+// signed once remains valid forever.
+//
+// A Verifier must not be modified after first use, but may then be used concurrently.
+type Verifier struct {
+	// TokenHash is the hashed bot token, as returned from HashBotToken. It is ignored if KeyRing, MAC or TokenSource
+	// is not nil.
+	TokenHash []byte
+
+	// MAC, if not nil, computes the expected hash of data instead of a key, so that the key can be kept out of this
+	// process.
+	MAC MACComputer
+
+	// TokenSource, if not nil, is consulted for the bot token on each verification instead of using TokenHash.
+	TokenSource TokenSource
+
+	// KeyRing, if not nil, is used to verify data against several bot tokens instead of TokenHash, MAC or TokenSource.
+	KeyRing *KeyRing
+
+	// MaxAge is the longest time after the auth date that the data is accepted. If MaxAge is zero, data of any age is
+	// accepted.
+	MaxAge time.Duration
+
+	// AllowedClockSkew is how far in the future the auth date may be before the data is rejected. Telegram's clock and
+	// the local clock may disagree slightly.
+	AllowedClockSkew time.Duration
+
+	// Now returns the current time. If Now is nil, time.Now is used.
+	Now func() time.Time
+
+	// ReplayStore, if not nil, records data as it is verified so that each payload from the login widget can only be
+	// used once. Payloads are remembered until MaxAge has passed, so a ReplayStore should be paired with a MaxAge.
+	ReplayStore ReplayStore
+
+	// DisallowUnknownFields causes data containing fields that this library doesn't know about to be rejected with
+	// ErrUnknownField, rather than verified and returned in User.Extra.
+	DisallowUnknownFields bool
+
+	// Limits, if not nil, bounds the size of data that will be parsed. If Limits is nil, DefaultLimits is used.
+	Limits *Limits
+}
+
+// ConvertAndVerifyForm is like the package-level ConvertAndVerifyForm, but also checks the auth date of the data and,
+// if v has a ReplayStore, that the data hasn't been used before.
+func (v *Verifier) ConvertAndVerifyForm(f url.Values) (User, error) {
+	u, ps, expectedMAC, err := parseUserFromForm(f, v.limits())
+	if err != nil {
+		return u, err
+	}
+	return v.verify(u, ps, expectedMAC)
+}
+
+// ConvertAndVerifyJSON is like the package-level ConvertAndVerifyJSON, but also checks the auth date of the data and,
+// if v has a ReplayStore, that the data hasn't been used before.
+func (v *Verifier) ConvertAndVerifyJSON(r io.Reader) (User, error) {
+	u, ps, expectedMAC, err := parseUserFromJSON(r, v.limits())
+	if err != nil {
+		return u, err
+	}
+	return v.verify(u, ps, expectedMAC)
+}
+
+func (v *Verifier) verify(u User, ps []pair, expectedMAC []byte) (User, error) {
+	if v.DisallowUnknownFields {
+		for k := range u.Extra {
+			return u, newFieldError(k, u.Extra[k], ErrUnknownField, nil)
+		}
+	}
+
+	switch {
+	case v.KeyRing != nil:
+		var err error
+		if u, err = v.KeyRing.verify(u, ps, expectedMAC, v.now()); err != nil {
+			return u, err
+		}
+	case v.MAC != nil:
+		if err := validateWith(ps, v.MAC, expectedMAC); err != nil {
+			return u, err
+		}
+	default:
+		tokenHash, err := v.tokenHash()
+		if err != nil {
+			return u, err
+		}
+		if !validate(ps, tokenHash, expectedMAC) {
+			return u, ErrInvalidHash
+		}
+	}
+
+	if err := v.CheckAuthDate(u.AuthDate); err != nil {
+		return u, err
+	}
+
+	if v.ReplayStore == nil {
+		return u, nil
+	}
+	// Once MaxAge has passed, the age check rejects the data, so there's no need to remember it any longer.
+	var expires time.Time
+	if v.MaxAge > 0 {
+		expires = u.AuthDate.Add(v.MaxAge)
+	}
+	key := strconv.FormatInt(u.ID, 10) + ":" + hex.EncodeToString(expectedMAC)
+	if added, err := v.ReplayStore.Add(key, expires); err != nil {
+		return u, fmt.Errorf("failure to record use of data: %w", err)
+	} else if !added {
+		return u, ErrReplayed
+	}
+	return u, nil
+}
+
+// CheckAuthDate returns an *AuthDateError if the provided auth date is not acceptable to v. It is useful to check
+// authenticated data other than a User, such as WebAppInitData.
+func (v *Verifier) CheckAuthDate(authDate time.Time) error {
+	now := v.now()
+	if authDate.After(now.Add(v.AllowedClockSkew)) {
+		return &AuthDateError{AuthDate: authDate, Err: ErrFromFuture}
+	}
+	if v.MaxAge > 0 && now.Sub(authDate) > v.MaxAge {
+		return &AuthDateError{AuthDate: authDate, Err: ErrExpired}
+	}
+	return nil
+}
+
+// tokenHash returns the hashed bot token from v.TokenSource if it is set, or v.TokenHash otherwise.
+func (v *Verifier) tokenHash() ([]byte, error) {
+	if v.TokenSource == nil {
+		return v.TokenHash, nil
+	}
+	t, err := v.TokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failure to get bot token: %w", err)
+	}
+	return t.Hash(), nil
+}
+
+// webAppSecretKey returns key if it isn't empty, or else the Mini App key for the current token of v.TokenSource.
+func (v *Verifier) webAppSecretKey(key []byte) ([]byte, error) {
+	if len(key) > 0 || v.TokenSource == nil {
+		return key, nil
+	}
+	t, err := v.TokenSource.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failure to get bot token: %w", err)
+	}
+	return t.WebAppSecretKey(), nil
+}
+
+func (v *Verifier) limits() Limits {
+	if v.Limits == nil {
+		return DefaultLimits
+	}
+	return *v.Limits
+}
+
+func (v *Verifier) now() time.Time {
+	if v.Now == nil {
+		return time.Now()
+	}
+	return v.Now()
+}
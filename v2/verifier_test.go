@@ -0,0 +1,150 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testAuthDate = time.Unix(1512345678, 0)
+
+var testForm = url.Values{
+	"auth_date":  {"1512345678"},
+	"first_name": {"John 🕶"},
+	"hash":       {"25409759c10beb29bd3f3fe1d16ee0605ac82eb2907d886e196d481371b91501"},
+	"id":         {"12345678"},
+	"last_name":  {"Smith"},
+	"photo_url":  {"https://t.me/i/userpic/320/jsmith.jpg"},
+	"username":   {"jsmith"},
+}
+
+func fixedClock(t time.Time) func() time.Time {
+	return func() time.Time { return t }
+}
+
+// testVerifier returns a Verifier that accepts testForm once, a minute after it was signed.
+func testVerifier() *Verifier {
+	return &Verifier{TokenHash: testBotTokenHash, MaxAge: time.Hour, Now: fixedClock(testAuthDate.Add(time.Minute))}
+}
+
+func TestVerifier_WithFreshData(t *testing.T) {
+	v := Verifier{
+		TokenHash: testBotTokenHash,
+		MaxAge:    time.Hour,
+		Now:       fixedClock(testAuthDate.Add(time.Minute)),
+	}
+	u, err := v.ConvertAndVerifyForm(testForm)
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if u.ID != 12345678 {
+		t.Errorf("ID should be 12345678, but was %d", u.ID)
+	}
+}
+
+func TestVerifier_WithExpiredData(t *testing.T) {
+	v := Verifier{
+		TokenHash: testBotTokenHash,
+		MaxAge:    time.Hour,
+		Now:       fixedClock(testAuthDate.Add(2 * time.Hour)),
+	}
+	_, err := v.ConvertAndVerifyForm(testForm)
+	if !errors.Is(err, ErrExpired) {
+		t.Fatalf("expected ErrExpired, but was %v", err)
+	}
+	var ade *AuthDateError
+	if !errors.As(err, &ade) || !ade.AuthDate.Equal(testAuthDate) {
+		t.Errorf("expected AuthDateError with auth date %v, but was %v", testAuthDate, err)
+	}
+}
+
+func TestVerifier_WithoutMaxAge(t *testing.T) {
+	v := Verifier{
+		TokenHash: testBotTokenHash,
+		Now:       fixedClock(testAuthDate.Add(24 * 365 * time.Hour)),
+	}
+	if _, err := v.ConvertAndVerifyForm(testForm); err != nil {
+		t.Errorf("failed to convert and verify: %v", err)
+	}
+}
+
+func TestVerifier_WithDataFromFuture(t *testing.T) {
+	v := Verifier{
+		TokenHash:        testBotTokenHash,
+		AllowedClockSkew: time.Minute,
+		Now:              fixedClock(testAuthDate.Add(-2 * time.Minute)),
+	}
+	if _, err := v.ConvertAndVerifyForm(testForm); !errors.Is(err, ErrFromFuture) {
+		t.Errorf("expected ErrFromFuture, but was %v", err)
+	}
+}
+
+func TestVerifier_WithinClockSkew(t *testing.T) {
+	v := Verifier{
+		TokenHash:        testBotTokenHash,
+		AllowedClockSkew: time.Minute,
+		Now:              fixedClock(testAuthDate.Add(-30 * time.Second)),
+	}
+	if _, err := v.ConvertAndVerifyForm(testForm); err != nil {
+		t.Errorf("failed to convert and verify: %v", err)
+	}
+}
+
+func TestVerifier_ChecksHashBeforeAge(t *testing.T) {
+	v := Verifier{
+		TokenHash: HashBotToken("987654321:wrong"),
+		MaxAge:    time.Hour,
+		Now:       fixedClock(testAuthDate.Add(2 * time.Hour)),
+	}
+	if _, err := v.ConvertAndVerifyForm(testForm); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestVerifier_WithExpiredJSON(t *testing.T) {
+	v := Verifier{
+		TokenHash: testBotTokenHash,
+		MaxAge:    time.Hour,
+		Now:       fixedClock(testAuthDate.Add(2 * time.Hour)),
+	}
+	_, err := v.ConvertAndVerifyJSON(strings.NewReader(`{
+		"auth_date": 1512345678,
+		"id": 12345678,
+		"hash": "180f7d26839de06e6ecb26148f181553d24e1c62153400da55ae31483ee62ad3"
+	}`))
+	if !errors.Is(err, ErrExpired) {
+		t.Errorf("expected ErrExpired, but was %v", err)
+	}
+}
+
+func TestVerifier_WithDisallowedUnknownField(t *testing.T) {
+	v := Verifier{
+		TokenHash:             testBotTokenHash,
+		DisallowUnknownFields: true,
+	}
+	_, err := v.ConvertAndVerifyForm(url.Values{
+		"allows_write_to_pm": {"true"},
+		"auth_date":          {"1512345678"},
+		"id":                 {"12345678"},
+		"hash":               {"0fe0d3e050af6fb6989da883e585ca520d2a8e53ed7c9532d95385f2aaf83f73"},
+	})
+	if !errors.Is(err, ErrUnknownField) {
+		t.Errorf("expected ErrUnknownField, but was %v", err)
+	}
+}
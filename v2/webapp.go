@@ -0,0 +1,190 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// A WebAppUser is a Telegram user as described by the initData of a Telegram Mini App. Unlike User, it is only ever
+// found nested inside WebAppInitData.
+//
+// For more detail, see https://core.telegram.org/bots/webapps#webappuser.
+type WebAppUser struct {
+	ID                    int64  `json:"id"`
+	IsBot                 bool   `json:"is_bot,omitempty"`
+	FirstName             string `json:"first_name"`
+	LastName              string `json:"last_name,omitempty"`
+	Username              string `json:"username,omitempty"`
+	LanguageCode          string `json:"language_code,omitempty"`
+	IsPremium             bool   `json:"is_premium,omitempty"`
+	AddedToAttachmentMenu bool   `json:"added_to_attachment_menu,omitempty"`
+	AllowsWriteToPM       bool   `json:"allows_write_to_pm,omitempty"`
+	PhotoURL              string `json:"photo_url,omitempty"`
+}
+
+// A WebAppChat is a chat as described by the initData of a Telegram Mini App.
+//
+// For more detail, see https://core.telegram.org/bots/webapps#webappchat.
+type WebAppChat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Username string `json:"username,omitempty"`
+	PhotoURL string `json:"photo_url,omitempty"`
+}
+
+// WebAppInitData is the data passed to a Telegram Mini App in Telegram.WebApp.initData.
+//
+// Absent fields are parsed as their zero values. For example, when the Mini App was not launched from a chat, Chat is
+// nil.
+//
+// For more detail, see https://core.telegram.org/bots/webapps#webappinitdata.
+type WebAppInitData struct {
+	QueryID      string
+	User         *WebAppUser
+	Receiver     *WebAppUser
+	Chat         *WebAppChat
+	ChatType     string
+	ChatInstance string
+	StartParam   string
+	CanSendAfter time.Duration
+	AuthDate     time.Time
+}
+
+// ConvertAndVerifyWebAppInitData accepts the raw query string from Telegram.WebApp.initData and parses it into the
+// returned WebAppInitData. The hash property of the input is used to validate the data before it is returned. The
+// secret key must be derived from the bot token with WebAppSecretKey; keys from HashBotToken will not validate, and
+// neither will an empty key.
+func ConvertAndVerifyWebAppInitData(initData string, secretKey []byte) (WebAppInitData, error) {
+	return convertAndVerifyWebAppInitData(initData, secretKey, DefaultLimits)
+}
+
+func convertAndVerifyWebAppInitData(initData string, secretKey []byte, l Limits) (WebAppInitData, error) {
+	if err := l.checkBytes(int64(len(initData))); err != nil {
+		return WebAppInitData{}, err
+	}
+	f, err := url.ParseQuery(initData)
+	if err != nil {
+		return WebAppInitData{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	d, ps, expectedMAC, err := parseWebAppInitData(f, l)
+	if err != nil {
+		return d, err
+	}
+	if expectedMAC == nil {
+		return d, newFieldError("hash", "", ErrMissingHash, nil)
+	}
+
+	if !validate(ps, secretKey, expectedMAC) {
+		return d, ErrInvalidHash
+	}
+
+	return d, nil
+}
+
+// WebAppSecretKey derives the key used to validate Mini App initData from a bot token. Mini Apps use a different key
+// from the login widget, so this is not interchangeable with HashBotToken.
+func WebAppSecretKey(token string) []byte {
+	mac := hmac.New(sha256.New, []byte("WebAppData"))
+	mac.Write([]byte(token))
+	return mac.Sum(nil)
+}
+
+// parseWebAppInitData parses Mini App initData. Unlike the other parsers, it doesn't require a hash, since data
+// validated by its signature doesn't need one. If there is no hash, the returned MAC is nil.
+func parseWebAppInitData(f url.Values, l Limits) (WebAppInitData, []pair, []byte, error) {
+	var d WebAppInitData
+	if err := l.checkFieldCount(len(f)); err != nil {
+		return d, nil, nil, err
+	}
+	ps := make([]pair, 0, len(f))
+	var expectedMAC []byte
+
+	for k, vs := range f {
+		if len(vs) != 1 {
+			return d, nil, nil, newFieldError(k, "", ErrNotSingleValue, nil)
+		}
+		v := vs[0]
+		if err := l.checkField(k, v); err != nil {
+			return d, nil, nil, err
+		}
+
+		if k == "hash" {
+			// This is only used to check validity, then is dropped.
+			expectedMAC = make([]byte, sha256.Size)
+			if err := decodeHash(v, expectedMAC); err != nil {
+				return d, nil, nil, err
+			}
+			continue
+		}
+
+		// Unlike the login widget, Telegram documents that every field other than the hash is signed, including ones
+		// this library doesn't know about.
+		ps = append(ps, pair{k, v})
+
+		switch k {
+		case "query_id":
+			d.QueryID = v
+		case "user":
+			d.User = &WebAppUser{}
+			if err := json.Unmarshal([]byte(v), d.User); err != nil {
+				return d, nil, nil, newFieldError(k, v, ErrMalformed, err)
+			}
+		case "receiver":
+			d.Receiver = &WebAppUser{}
+			if err := json.Unmarshal([]byte(v), d.Receiver); err != nil {
+				return d, nil, nil, newFieldError(k, v, ErrMalformed, err)
+			}
+		case "chat":
+			d.Chat = &WebAppChat{}
+			if err := json.Unmarshal([]byte(v), d.Chat); err != nil {
+				return d, nil, nil, newFieldError(k, v, ErrMalformed, err)
+			}
+		case "chat_type":
+			d.ChatType = v
+		case "chat_instance":
+			d.ChatInstance = v
+		case "start_param":
+			d.StartParam = v
+		case "can_send_after":
+			seconds, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return d, nil, nil, newFieldError(k, v, ErrMalformed, err)
+			}
+			d.CanSendAfter = time.Duration(seconds) * time.Second
+		case "auth_date":
+			// Fractional seconds are lost by this conversion.
+			seconds, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return d, nil, nil, newFieldError(k, v, ErrMalformed, err)
+			}
+			d.AuthDate = time.Unix(seconds, 0)
+		}
+	}
+
+	if err := checkRequired(ps, "auth_date"); err != nil {
+		return d, nil, nil, err
+	}
+
+	return d, ps, expectedMAC, nil
+}
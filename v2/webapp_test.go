@@ -0,0 +1,116 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+const testWebAppUser = `{"id":12345678,"first_name":"John","last_name":"Smith","username":"jsmith","language_code":"en","allows_write_to_pm":true}`
+
+func TestConvertAndVerifyWebAppInitData_WithValidCredentials(t *testing.T) {
+	d, err := ConvertAndVerifyWebAppInitData(url.Values{
+		"auth_date": {"1512345678"},
+		"hash":      {"11a0a5b7e2e2473efff79464d4bef0789e06828053dfffe369df9d78543a7707"},
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"user":      {testWebAppUser},
+	}.Encode(), WebAppSecretKey(testBotToken))
+	if err != nil {
+		t.Fatalf("failed to convert and verify: %v", err)
+	}
+	if !time.Date(2017, time.December, 4, 0, 1, 18, 0, time.UTC).Equal(d.AuthDate) {
+		t.Errorf("auth date should be 2017-12-04T00:01:18Z, but was %v", d.AuthDate)
+	}
+
+	if d.QueryID != "AAHdF6IQAAAAAN0XohDhrOrc" {
+		t.Errorf("query ID should be AAHdF6IQAAAAAN0XohDhrOrc, but was %v", d.QueryID)
+	}
+
+	if d.User == nil {
+		t.Fatal("user should be present, but was nil")
+	}
+	if d.User.ID != 12345678 {
+		t.Errorf("user ID should be 12345678, but was %d", d.User.ID)
+	}
+	if d.User.Username != "jsmith" {
+		t.Errorf("username should be jsmith, but was %s", d.User.Username)
+	}
+	if !d.User.AllowsWriteToPM {
+		t.Error("user should allow writing to PM, but didn't")
+	}
+
+	if d.Chat != nil {
+		t.Errorf("chat should be absent, but was %v", d.Chat)
+	}
+	if d.Receiver != nil {
+		t.Errorf("receiver should be absent, but was %v", d.Receiver)
+	}
+}
+
+func TestConvertAndVerifyWebAppInitData_WithEmptyKey(t *testing.T) {
+	if _, err := ConvertAndVerifyWebAppInitData(testInitData, nil); err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyWebAppInitData_WithLoginWidgetKey(t *testing.T) {
+	_, err := ConvertAndVerifyWebAppInitData(url.Values{
+		"auth_date": {"1512345678"},
+		"hash":      {"11a0a5b7e2e2473efff79464d4bef0789e06828053dfffe369df9d78543a7707"},
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"user":      {testWebAppUser},
+	}.Encode(), testBotTokenHash)
+	if err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyWebAppInitData_WithUnsignedField(t *testing.T) {
+	_, err := ConvertAndVerifyWebAppInitData(url.Values{
+		"auth_date":   {"1512345678"},
+		"hash":        {"11a0a5b7e2e2473efff79464d4bef0789e06828053dfffe369df9d78543a7707"},
+		"query_id":    {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"start_param": {"injected"},
+		"user":        {testWebAppUser},
+	}.Encode(), WebAppSecretKey(testBotToken))
+	if err != ErrInvalidHash {
+		t.Errorf("expected ErrInvalidHash, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyWebAppInitData_WithoutHash(t *testing.T) {
+	_, err := ConvertAndVerifyWebAppInitData(url.Values{
+		"auth_date": {"1512345678"},
+		"query_id":  {"AAHdF6IQAAAAAN0XohDhrOrc"},
+		"user":      {testWebAppUser},
+	}.Encode(), WebAppSecretKey(testBotToken))
+	if !errors.Is(err, ErrMissingHash) {
+		t.Errorf("expected ErrMissingHash, but was %v", err)
+	}
+}
+
+func TestConvertAndVerifyWebAppInitData_WithMalformedUser(t *testing.T) {
+	_, err := ConvertAndVerifyWebAppInitData(url.Values{
+		"auth_date": {"1512345678"},
+		"hash":      {"11a0a5b7e2e2473efff79464d4bef0789e06828053dfffe369df9d78543a7707"},
+		"user":      {"{"},
+	}.Encode(), WebAppSecretKey(testBotToken))
+	if err == nil {
+		t.Error("should have returned error, but was nil")
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// WidgetScript is the URL of the script that renders the login widget.
+const WidgetScript = "https://telegram.org/js/telegram-widget.js?22"
+
+var (
+	botNamePattern    = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{3,31}$`)
+	jsFunctionPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+)
+
+var widgetTemplate = template.Must(template.New("widget").Parse(
+	`<script async src="{{.Script}}" data-telegram-login="{{.BotName}}"` +
+		`{{with .Size}} data-size="{{.}}"{{end}}` +
+		`{{with .Radius}} data-radius="{{.}}"{{end}}` +
+		`{{if .HideUserpic}} data-userpic="false"{{end}}` +
+		`{{with .RequestAccess}} data-request-access="{{.}}"{{end}}` +
+		`{{with .Language}} data-lang="{{.}}"{{end}}` +
+		`{{with .AuthURL}} data-auth-url="{{.}}"{{end}}` +
+		`{{with .OnAuth}} data-onauth="{{.}}"{{end}}` +
+		`></script>`))
+
+// A Widget configures the Telegram login widget. Its HTML method renders the script tag that displays the widget.
+//
+// Exactly one of AuthURL and OnAuth must be set. With AuthURL, Telegram redirects the browser there with the user data
+// in the query string, which LoginHandler can verify. With OnAuth, a JavaScript function is called with the user data,
+// which ConvertAndVerifyJSON can verify once the page sends it to the server. LoginHandler.Widget sets AuthURL to match
+// a LoginHandler.
+//
+// For more detail, see https://core.telegram.org/widgets/login.
+type Widget struct {
+	// BotName is the username of the bot, without the leading @.
+	BotName string
+
+	// Size is the size of the button: "large", "medium" or "small". If empty, Telegram's default is used.
+	Size string
+
+	// Radius, if not nil, is the corner radius of the button in pixels.
+	Radius *int
+
+	// HideUserpic hides the user's photo from the button.
+	HideUserpic bool
+
+	// RequestAccess, if not empty, asks the user for more permissions. The only value Telegram supports is "write".
+	RequestAccess string
+
+	// Language, if not empty, is the IETF language tag for the button, such as "en".
+	Language string
+
+	// AuthURL is where Telegram redirects the browser after login.
+	AuthURL string
+
+	// OnAuth is the name of a global JavaScript function to call with the user data after login, such as
+	// "onTelegramAuth". It is called with a single argument.
+	OnAuth string
+}
+
+// HTML validates w and returns the script tag that displays the widget. The result is safe to include in an
+// html/template.
+func (w Widget) HTML() (template.HTML, error) {
+	if !botNamePattern.MatchString(w.BotName) {
+		return "", fmt.Errorf("invalid bot name %q", w.BotName)
+	}
+	switch w.Size {
+	case "", "large", "medium", "small":
+	default:
+		return "", fmt.Errorf("unsupported size %q", w.Size)
+	}
+	switch w.RequestAccess {
+	case "", "write":
+	default:
+		return "", fmt.Errorf("unsupported request_access %q", w.RequestAccess)
+	}
+	if (w.AuthURL == "") == (w.OnAuth == "") {
+		return "", errors.New("exactly one of AuthURL and OnAuth must be set")
+	}
+
+	data := struct {
+		Script        string
+		BotName       string
+		Size          string
+		Radius        string
+		HideUserpic   bool
+		RequestAccess string
+		Language      string
+		AuthURL       string
+		OnAuth        template.JS
+	}{
+		Script:        WidgetScript,
+		BotName:       w.BotName,
+		Size:          w.Size,
+		HideUserpic:   w.HideUserpic,
+		RequestAccess: w.RequestAccess,
+		Language:      w.Language,
+		AuthURL:       w.AuthURL,
+	}
+	if w.Radius != nil {
+		data.Radius = strconv.Itoa(*w.Radius)
+	}
+	if w.OnAuth != "" {
+		// html/template treats data-onauth as an event handler, so it must be given JavaScript. Only a function name
+		// is accepted so that nothing else can be injected.
+		if !jsFunctionPattern.MatchString(w.OnAuth) {
+			return "", fmt.Errorf("OnAuth %q is not a JavaScript function name", w.OnAuth)
+		}
+		data.OnAuth = template.JS(w.OnAuth + "(user)")
+	}
+
+	var b strings.Builder
+	if err := widgetTemplate.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return template.HTML(b.String()), nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package telegramwidget
+
+import "testing"
+
+func TestWidget_WithAuthURL(t *testing.T) {
+	radius := 0
+	h, err := Widget{
+		BotName:       "samplebot",
+		Size:          "large",
+		Radius:        &radius,
+		HideUserpic:   true,
+		RequestAccess: "write",
+		Language:      "en",
+		AuthURL:       "https://example.com/login?next=/a&b",
+	}.HTML()
+	if err != nil {
+		t.Fatalf("failed to render: %v", err)
+	}
+	want := `<script async src="https://telegram.org/js/telegram-widget.js?22" data-telegram-login="samplebot" data-size="large" data-radius="0" data-userpic="false" data-request-access="write" data-lang="en" data-auth-url="https://example.com/login?next=/a&amp;b"></script>`
+	if string(h) != want {
+		t.Errorf("HTML should be %v, but was %v", want, h)
+	}
+}
+
+func TestWidget_WithOnAuth(t *testing.T) {
+	h, err := Widget{BotName: "samplebot", OnAuth: "onTelegramAuth"}.HTML()
+	if err != nil {
+		t.Fatalf("failed to render: %v", err)
+	}
+	want := `<script async src="https://telegram.org/js/telegram-widget.js?22" data-telegram-login="samplebot" data-onauth="onTelegramAuth(user)"></script>`
+	if string(h) != want {
+		t.Errorf("HTML should be %v, but was %v", want, h)
+	}
+}
+
+func TestWidget_EscapesAuthURL(t *testing.T) {
+	h, err := Widget{BotName: "samplebot", AuthURL: `javascript:alert(1)`}.HTML()
+	if err != nil {
+		t.Fatalf("failed to render: %v", err)
+	}
+	want := `<script async src="https://telegram.org/js/telegram-widget.js?22" data-telegram-login="samplebot" data-auth-url="#ZgotmplZ"></script>`
+	if string(h) != want {
+		t.Errorf("HTML should be %v, but was %v", want, h)
+	}
+}
+
+func TestWidget_WithInvalidOptions(t *testing.T) {
+	for _, w := range []Widget{
+		{BotName: "samplebot"},
+		{BotName: "samplebot", AuthURL: "/login", OnAuth: "f"},
+		{BotName: `samplebot" onload="alert(1)`, AuthURL: "/login"},
+		{BotName: "samplebot", OnAuth: "alert(1);f"},
+		{BotName: "samplebot", AuthURL: "/login", Size: "huge"},
+		{BotName: "samplebot", AuthURL: "/login", RequestAccess: "read"},
+	} {
+		if _, err := w.HTML(); err == nil {
+			t.Errorf("%+v should be invalid, but wasn't", w)
+		}
+	}
+}